@@ -0,0 +1,50 @@
+package k8sforward
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// AppForward is port-forwarding to an app started by StartApps.
+type AppForward struct {
+	AppName      string
+	LocalAddress string
+	Handle       *Handle
+}
+
+// StartApps starts port-forwarding to each of appNames, as with Start, from consecutive local ports on localhost beginning
+// with startPort, so that a set of apps can be brought up without assigning a local port to each. newSettings returns
+// the Settings for each app, whose AppName and LocalAddress are then set. The forwards are returned in the order of
+// appNames. If any forward cannot be started, those already started are stopped and the error is returned.
+func StartApps(ctx context.Context, appNames []string, startPort int, newSettings func(appName string) *Settings) ([]AppForward, error) {
+	if startPort < 1 || startPort+len(appNames)-1 > 65535 {
+		return nil, &Error{Code: CodeInvalidSettings, Err: fmt.Errorf("local ports from %d for %d apps must be between 1 and 65535", startPort, len(appNames))}
+	}
+
+	seen := map[string]bool{}
+	for _, appName := range appNames {
+		if seen[appName] {
+			return nil, &Error{Code: CodeInvalidSettings, Err: fmt.Errorf("app '%s' is given more than once", appName)}
+		}
+		seen[appName] = true
+	}
+
+	forwards := make([]AppForward, 0, len(appNames))
+	for i, appName := range appNames {
+		s := newSettings(appName)
+		s.AppName = appName
+		s.LocalAddress = net.JoinHostPort("localhost", strconv.Itoa(startPort+i))
+
+		h, err := Start(ctx, s)
+		if err != nil {
+			for _, forward := range forwards {
+				forward.Handle.Stop()
+			}
+			return nil, fmt.Errorf("error starting port-forwarding to app '%s': %w", appName, err)
+		}
+		forwards = append(forwards, AppForward{AppName: appName, LocalAddress: s.LocalAddress, Handle: h})
+	}
+	return forwards, nil
+}
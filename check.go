@@ -0,0 +1,34 @@
+package k8sforward
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+// checkDialTimeout is the timeout for connecting through port-forwarding in Check.
+const checkDialTimeout = 10 * time.Second
+
+// Check initiates port-forwarding, confirms that a TCP connection can be made through it once it is ready, and stops it,
+// so that whether the remote port is reachable can be checked cheaply. If hold is given, port-forwarding is kept open for
+// that long after the connection is confirmed. Without a local port, such as with LocalAddress 'localhost:0', readiness alone is confirmed.
+func (s *Settings) Check(ctx context.Context, hold time.Duration) error {
+	return s.WithForward(ctx, func(localAddress string) error {
+		if s.localPort != "0" {
+			conn, err := net.DialTimeout("tcp", localAddress, checkDialTimeout)
+			if err != nil {
+				return &Error{Code: CodeForwardFailed, Err: fmt.Errorf("error connecting through port-forwarding at %s: %w", localAddress, err)}
+			}
+			_ = conn.Close()
+		}
+
+		if hold > 0 {
+			select {
+			case <-time.After(hold):
+			case <-ctx.Done():
+			}
+		}
+		return nil
+	})
+}
@@ -2,47 +2,398 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
 
 	"github.com/merlincox/k8sforward"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 func main() {
 	if err := run(); err != nil {
+		if code := k8sforward.ErrorCode(err); code != "" {
+			_, _ = fmt.Fprintf(os.Stderr, "[%s] ", code)
+		}
 		_, _ = fmt.Fprint(os.Stderr, err.Error())
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
+// exitCode returns the process exit code for err, by its category, so that scripts can react to the cause.
+func exitCode(err error) int {
+	switch k8sforward.ErrorCode(err) {
+	case k8sforward.CodeInvalidSettings:
+		return 2
+	case k8sforward.CodeKubeconfigInvalid, k8sforward.CodeContextNotFound, k8sforward.CodeClusterUnreachable, k8sforward.CodeForbidden:
+		return 3
+	case k8sforward.CodeNoPods:
+		return 4
+	case k8sforward.CodeForwardFailed, k8sforward.CodeLocalBindFailed:
+		return 5
+	}
+	return 1
+}
+
+// invalidSettings returns err as an Error with CodeInvalidSettings.
+func invalidSettings(err error) error {
+	return &k8sforward.Error{Code: k8sforward.CodeInvalidSettings, Err: err}
+}
+
 func run() error {
 	contextName := flag.String("n", "", "k8s context name")
 	appName := flag.String("app", "", "k8s app name")
-	localAddress := flag.String("local-address", "", "local address to use (such as 'localhost:8080')")
-	remotePort := flag.String("remote-port", "", "remote TCP port to use")
+	target := flag.String("target", "", "k8s resource in kind/name format, such as 'deployment/api', used instead of -app (optional)")
+	checkServiceMembership := flag.Bool("check-service-membership", false, "with a Service -target, warn if the pod is not its ready endpoint or backs other Services (optional)")
+	readyEndpointsOnly := flag.Bool("ready-endpoints-only", false, "with a Service -target, select only pods which are its ready endpoints (optional)")
+	var localAddresses, remotePorts stringsFlag
+	flag.Var(&localAddresses, "local-address", "local address to use (such as 'localhost:8080'), repeatable with -remote-port for several forwards")
+	allowPublicBind := flag.Bool("allow-public-bind", false, "allow a local address of 0.0.0.0, exposing port-forwarding to the network (optional)")
+	localSocketPath := flag.String("local-socket", "", "Unix domain socket path to relay to the local address (optional)")
+	listenBacklog := flag.Int("listen-backlog", 0, "listen backlog of the Unix domain socket (optional)")
+	trace := flag.Bool("trace", false, "relay connections and report transfer statistics once stopped (optional)")
+	deferAccept := flag.Bool("defer-accept", false, "do not accept local connections until port-forwarding is ready (optional)")
+	flag.Var(&remotePorts, "remote-port", "remote TCP port to use, repeatable with -local-address for several forwards")
 
-	versionName := flag.String("app-version", "", "app version (optional)")
+	appNamePrefix := flag.Bool("app-prefix", false, "treat the k8s app name as a prefix (optional)")
+	appLabelKey := flag.String("app-label", "app", "label key selected for by the k8s app name (optional)")
+	versionLabelKey := flag.String("app-version-label", "version", "label key selected for by the app version (optional)")
+	strictPort := flag.Bool("strict-port", false, "fail if the remote port is not declared by the pod (optional)")
+	checkLocalPort := flag.Bool("check-local-port", false, "fail early if the local port is already in use (optional)")
+	versionName := flag.String("app-version", "", "app version, or comma-separated versions (optional)")
+	annotations := flag.String("annotations", "", "comma-separated key=value annotations that pods must have (optional)")
+	statefulSetName := flag.String("statefulset", "", "k8s StatefulSet name, used instead of -app (optional)")
+	ordinal := flag.Int("ordinal", 0, "ordinal of the StatefulSet pod to use (optional)")
+	jobName := flag.String("job", "", "k8s Job name, used instead of -app (optional)")
+	deploymentName := flag.String("deployment", "", "k8s Deployment name, used instead of -app (optional)")
+	followLatest := flag.Bool("follow-latest", false, "rebind to the pods of each new rollout of the -deployment (optional)")
+	nodeName := flag.String("node", "", "k8s node name to select pods scheduled on (optional)")
+	minPodAge := flag.Duration("min-pod-age", 0, "minimum time a pod must have been ready to be selected, such as '30s' (optional)")
+	selector := flag.String("selector", "", "k8s label selector for pods, used with -field-selector instead of -app and other pod flags (optional)")
+	requireSingleApp := flag.Bool("require-single-app", false, "fail if the matching pods are of more than one app (optional)")
+	preferZone := flag.String("prefer-zone", "", "topology zone whose pods are preferred (optional)")
+	fieldSelector := flag.String("field-selector", "", "additional k8s field selector for pods (optional)")
+	includeNonRunning := flag.Bool("include-non-running", false, "select pods in any phase, not only running pods (optional)")
+	listLimit := flag.Int64("list-limit", 0, "page size for listing pods (optional)")
+	allNamespaces := flag.Bool("all-namespaces", false, "select pods from all namespaces (optional)")
 	kubeconfigPath := flag.String("kubeconfig-path", "", "kubeconfig Path (optional)")
+	authInfoOverride := flag.String("user", "", "kubeconfig user to use instead of the k8s context's (optional)")
+	clusterOverride := flag.String("cluster", "", "kubeconfig cluster to use instead of the k8s context's (optional)")
+	proxyURL := flag.String("proxy-url", "", "proxy URL for the k8s API server (optional)")
+	insecureSkipTLSVerify := flag.Bool("insecure-skip-tls-verify", false, "do not verify the k8s API server certificate, which is insecure (optional)")
+	tlsServerName := flag.String("tls-server-name", "", "server name to verify the k8s API server certificate against (optional)")
+	apiServer := flag.String("api-server", "", "URL of the k8s API server to use without a kubeconfig, instead of -n (optional)")
+	namespace := flag.String("namespace", "", "namespace to select pods from instead of the k8s context's (optional)")
+	tokenFile := flag.String("token-file", "", "path to a bearer token used instead of the kubeconfig's credentials (optional)")
+	clientCertificate := flag.String("client-certificate", "", "path to a PEM-encoded client certificate used instead of the kubeconfig's (optional)")
+	clientKey := flag.String("client-key", "", "path to the PEM-encoded key of -client-certificate (optional)")
+	certificateAuthority := flag.String("certificate-authority", "", "path to a PEM-encoded certificate authority bundle used instead of the kubeconfig's (optional)")
+	impersonate := flag.String("as", "", "user to impersonate (optional)")
+	impersonateGroups := flag.String("as-group", "", "comma-separated groups to impersonate (optional)")
+	userAgent := flag.String("user-agent", "", "User-Agent for k8s API requests (optional)")
+	extraHeaders := flag.String("headers", "", "comma-separated key=value headers for k8s API requests (optional)")
+	qps := flag.Float64("qps", 0, "maximum k8s API requests per second (optional)")
+	burst := flag.Int("burst", 0, "maximum burst of k8s API requests (optional)")
+	requestTimeout := flag.Duration("request-timeout", 0, "timeout for each k8s API request, such as '10s' (optional)")
+	transport := flag.String("transport", k8sforward.TransportAuto, "port-forwarding transport: 'auto', 'spdy' or 'websocket' (optional)")
+	grpcHealthCheck := flag.Bool("grpc-health-check", false, "wait for a gRPC health check through the forward to pass before it is ready (optional)")
+	grpcHealthService := flag.String("grpc-health-service", "", "service name for the gRPC health check (optional)")
+	maxDuration := flag.Duration("max-duration", 0, "duration after which to stop port-forwarding, such as '1h' (optional)")
+	waitForReadyPod := flag.Duration("wait-for-ready-pod", 0, "maximum time to wait for a matching pod to be ready, such as '2m' (optional)")
+	pollInterval := flag.Duration("poll-interval", 0, "interval between polls while waiting, such as '500ms' (optional, defaults to 1s)")
+	readyTimeout := flag.Duration("ready-timeout", 0, "maximum time for port-forwarding to become ready, such as '30s' (optional)")
+	keepAlive := flag.Duration("keepalive", 0, "interval at which to send keepalive connections through the forward, such as '30s' (optional)")
+	stopTimeout := flag.Duration("stop-timeout", 0, "maximum time to wait for port-forwarding to stop, such as '10s' (optional)")
+	drainTimeout := flag.Duration("drain-timeout", 0, "maximum time to wait on stopping, including on Ctrl+C, for connections relayed with -local-socket, -defer-accept or -trace to finish, such as '30s' (optional)")
+	reconnect := flag.Bool("reconnect", false, "re-establish port-forwarding when it fails (optional)")
+	retryInitial := flag.Bool("retry-initial", false, "retry port-forwarding until it first becomes ready (optional)")
+	rotateOnNotReady := flag.Bool("rotate-on-not-ready", false, "rotate to another pod when the pod forwarded to is not ready (optional)")
+	notReadyThreshold := flag.Duration("not-ready-threshold", 0, "time the pod may not be ready for before rotating, such as '10s' (optional)")
+	showCommand := flag.Bool("show-command", false, "write the equivalent kubectl command (optional)")
+	streamLogs := flag.Bool("logs", false, "stream the logs of the selected pod to stderr (optional)")
+	verbose := flag.Bool("verbose", false, "write diagnostic messages (optional)")
 	silent := flag.Bool("silent", false, "silence non-error output (optional)")
+	silentErr := flag.Bool("silent-err", false, "silence non-fatal error output (optional)")
+	outputFormat := flag.String("output", "text", "output format, 'text' or 'json' (optional)")
+	check := flag.Bool("check", false, "check that port-forwarding becomes ready and can be connected through, then exit (optional)")
+	checkHold := flag.Duration("check-hold", 0, "time to keep port-forwarding open for with -check, such as '5s' (optional)")
+	configPath := flag.String("config", "", "YAML or JSON file of the settings of one or more forwards, used instead of the other settings flags (optional)")
+	showVersion := flag.Bool("version", false, "print version information and exit")
 
 	flag.Parse()
-	settings := &k8sforward.Settings{
-		ContextName:    *contextName,
-		AppName:        *appName,
-		LocalAddress:   *localAddress,
-		RemotePort:     *remotePort,
-		VersionName:    *versionName,
-		KubeconfigPath: *kubeconfigPath,
+
+	if *showVersion {
+		_, err := fmt.Fprintf(os.Stdout, "k8sforward %s\n", k8sforward.Version())
+		return err
 	}
-	if silent != nil && *silent {
-		settings.Out = io.Discard
+
+	if *configPath != "" {
+		if err := checkConfigFlags(); err != nil {
+			return invalidSettings(err)
+		}
 	}
 
-	if err := settings.Validate(); err != nil {
-		return err
+	annotationSelector, err := labels.ConvertSelectorToLabelsMap(*annotations)
+	if err != nil {
+		return invalidSettings(fmt.Errorf("annotations must be comma-separated key=value pairs but were '%s'", *annotations))
+	}
+
+	headers, err := parseHeaders(*extraHeaders)
+	if err != nil {
+		return invalidSettings(err)
+	}
+
+	clientCertData, err := readOptionalFile(*clientCertificate)
+	if err != nil {
+		return invalidSettings(err)
+	}
+	clientKeyData, err := readOptionalFile(*clientKey)
+	if err != nil {
+		return invalidSettings(err)
+	}
+	caData, err := readOptionalFile(*certificateAuthority)
+	if err != nil {
+		return invalidSettings(err)
+	}
+
+	if len(localAddresses) > 1 || len(remotePorts) > 1 {
+		if len(localAddresses) != len(remotePorts) {
+			return invalidSettings(fmt.Errorf("-local-address and -remote-port must be given the same number of times for several forwards"))
+		}
+		if *localSocketPath != "" {
+			return invalidSettings(fmt.Errorf("-local-socket cannot be used with several forwards"))
+		}
+	}
+	if len(remotePorts) == 0 {
+		// Let validation report the missing remote port.
+		remotePorts = stringsFlag{""}
+	}
+
+	newSettings := func(localAddress, remotePort string) *k8sforward.Settings {
+		settings := &k8sforward.Settings{
+			ContextName:            *contextName,
+			AppName:                *appName,
+			Target:                 *target,
+			ReadyEndpointsOnly:     *readyEndpointsOnly,
+			CheckServiceMembership: *checkServiceMembership,
+			LocalAddress:           localAddress,
+			AllowPublicBind:        *allowPublicBind,
+			LocalSocketPath:        *localSocketPath,
+			ListenBacklog:          *listenBacklog,
+			DeferAccept:            *deferAccept,
+			Trace:                  *trace,
+			RemotePort:             remotePort,
+			AppLabelKey:            *appLabelKey,
+			VersionLabelKey:        *versionLabelKey,
+			StrictPort:             *strictPort,
+			CheckLocalPort:         *checkLocalPort,
+			AppNamePrefix:          *appNamePrefix,
+			VersionName:            *versionName,
+			AnnotationSelector:     annotationSelector,
+			StatefulSetName:        *statefulSetName,
+			Ordinal:                *ordinal,
+			JobName:                *jobName,
+			DeploymentName:         *deploymentName,
+			FollowLatest:           *followLatest,
+			NodeName:               *nodeName,
+			PreferZone:             *preferZone,
+			RequireSingleApp:       *requireSingleApp,
+			MinPodAge:              *minPodAge,
+			FieldSelector:          *fieldSelector,
+			IncludeNonRunning:      *includeNonRunning,
+			ListLimit:              *listLimit,
+			AllNamespaces:          *allNamespaces,
+			KubeconfigPath:         *kubeconfigPath,
+			AuthInfoOverride:       *authInfoOverride,
+			ClusterOverride:        *clusterOverride,
+			In:                     os.Stdin,
+			StreamLogs:             *streamLogs,
+			Verbose:                *verbose,
+			ShowEquivalentCommand:  *showCommand,
+			OutputFormat:           *outputFormat,
+			ProxyURL:               *proxyURL,
+			InsecureSkipTLSVerify:  *insecureSkipTLSVerify,
+			TLSServerName:          *tlsServerName,
+			APIServer:              *apiServer,
+			Namespace:              *namespace,
+			BearerTokenFile:        *tokenFile,
+			ClientCertData:         clientCertData,
+			ClientKeyData:          clientKeyData,
+			CAData:                 caData,
+			Impersonate:            *impersonate,
+			UserAgent:              *userAgent,
+			ExtraHeaders:           headers,
+			QPS:                    float32(*qps),
+			Burst:                  *burst,
+			RequestTimeout:         *requestTimeout,
+			Transport:              *transport,
+			GRPCHealthCheck:        *grpcHealthCheck,
+			GRPCHealthService:      *grpcHealthService,
+			MaxDuration:            *maxDuration,
+			WaitForReadyPod:        *waitForReadyPod,
+			PollInterval:           *pollInterval,
+			ReadyTimeout:           *readyTimeout,
+			KeepAlive:              *keepAlive,
+			StopTimeout:            *stopTimeout,
+			DrainTimeout:           *drainTimeout,
+			RetryInitial:           *retryInitial,
+			Reconnect:              *reconnect,
+			RotateOnNotReady:       *rotateOnNotReady,
+			NotReadyThreshold:      *notReadyThreshold,
+		}
+		if *selector != "" {
+			settings.Selector = &k8sforward.PodSelector{LabelSelector: *selector, FieldSelector: *fieldSelector}
+		}
+		if *impersonateGroups != "" {
+			settings.ImpersonateGroups = strings.Split(*impersonateGroups, ",")
+		}
+
+		return settings
+	}
+
+	var forwards []*k8sforward.Settings
+	if *configPath != "" {
+		if forwards, err = k8sforward.LoadSettings(*configPath); err != nil {
+			return invalidSettings(err)
+		}
+		for _, settings := range forwards {
+			settings.In = os.Stdin
+		}
+	} else {
+		forwards = make([]*k8sforward.Settings, len(remotePorts))
+		for i, remotePort := range remotePorts {
+			var localAddress string
+			if i < len(localAddresses) {
+				localAddress = localAddresses[i]
+			}
+			forwards[i] = newSettings(localAddress, remotePort)
+		}
+	}
+
+	for _, settings := range forwards {
+		if silent != nil && *silent {
+			settings.Out = io.Discard
+		}
+		if silentErr != nil && *silentErr {
+			settings.ErrOut = io.Discard
+		}
+		if err := settings.Validate(); err != nil {
+			return invalidSettings(err)
+		}
+	}
+
+	// Port-forwarding is stopped on an interrupt by cancelling its context, so that relayed connections are drained
+	// with -drain-timeout rather than cut. A second interrupt ends the process at once.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if *check {
+		for _, settings := range forwards {
+			if err := settings.Check(ctx, *checkHold); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(os.Stdout, "Port-forwarding from %s is reachable\n", settings.LocalAddress)
+		}
+		return nil
+	}
+
+	defer func() {
+		for _, settings := range forwards {
+			if settings.Trace {
+				reportTrace(settings)
+			}
+		}
+	}()
+
+	if len(forwards) == 1 {
+		return k8sforward.Init(ctx, forwards[0])
+	}
+
+	// Run the forwards concurrently, stopping all of them once any fails.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(forwards))
+	var wg sync.WaitGroup
+	for i, settings := range forwards {
+		settings.CancelFn = cancel
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = k8sforward.Init(ctx, settings)
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// configFlags are the flags which may be given with -config, as they do not set settings given by the file.
+var configFlags = map[string]bool{"config": true, "silent": true, "silent-err": true, "check": true, "check-hold": true}
+
+// checkConfigFlags returns an error if any flag setting settings is given with -config.
+func checkConfigFlags() error {
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		if err == nil && !configFlags[f.Name] {
+			err = fmt.Errorf("-%s cannot be given with -config", f.Name)
+		}
+	})
+	return err
+}
+
+// reportTrace writes the transfer statistics of settings to stderr.
+func reportTrace(settings *k8sforward.Settings) {
+	stats := settings.TraceStats()
+	_, _ = fmt.Fprintf(os.Stderr, "Relayed %d connections from %s: %d bytes sent, %d bytes received, %s dialling, %s connected\n",
+		stats.Connections, settings.LocalAddress, stats.BytesSent, stats.BytesReceived, stats.DialTime, stats.ConnectionTime)
+}
+
+// readOptionalFile returns the contents of the file at path, or nil if path is empty.
+func readOptionalFile(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
 	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// parseHeaders parses comma-separated key=value headers. Unlike annotations, header values are not restricted to label values.
+func parseHeaders(headers string) (map[string]string, error) {
+	if headers == "" {
+		return nil, nil
+	}
+	parsed := map[string]string{}
+	for _, header := range strings.Split(headers, ",") {
+		key, value, ok := strings.Cut(header, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("headers must be comma-separated key=value pairs but were '%s'", headers)
+		}
+		parsed[strings.TrimSpace(key)] = value
+	}
+	return parsed, nil
+}
+
+// stringsFlag is a flag which may be given more than once, collecting each value.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
 
-	return k8sforward.Init(context.Background(), settings)
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
 }
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"testing"
+
+	"github.com/merlincox/k8sforward"
+)
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "none", headers: ""},
+		{name: "single", headers: "X-Team=platform", want: map[string]string{"X-Team": "platform"}},
+		{name: "several", headers: "X-Team=platform, X-Env=dev", want: map[string]string{"X-Team": "platform", "X-Env": "dev"}},
+		{name: "value with equals and spaces", headers: "Authorization=Basic a=b c", want: map[string]string{"Authorization": "Basic a=b c"}},
+		{name: "empty value", headers: "X-Empty=", want: map[string]string{"X-Empty": ""}},
+		{name: "missing value", headers: "X-Team", wantErr: true},
+		{name: "missing key", headers: "=platform", wantErr: true},
+		{name: "trailing comma", headers: "X-Team=platform,", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseHeaders(test.headers)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseHeaders(%q) returned error %v, want error: %t", test.headers, err, test.wantErr)
+			}
+			if !maps.Equal(got, test.want) {
+				t.Errorf("parseHeaders(%q) returned %v, want %v", test.headers, got, test.want)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	coded := func(code k8sforward.Code) error {
+		return &k8sforward.Error{Code: code, Err: errors.New("failed")}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "invalid settings", err: coded(k8sforward.CodeInvalidSettings), want: 2},
+		{name: "invalid kubeconfig", err: coded(k8sforward.CodeKubeconfigInvalid), want: 3},
+		{name: "context not found", err: coded(k8sforward.CodeContextNotFound), want: 3},
+		{name: "cluster unreachable", err: coded(k8sforward.CodeClusterUnreachable), want: 3},
+		{name: "forbidden", err: coded(k8sforward.CodeForbidden), want: 3},
+		{name: "no pods", err: coded(k8sforward.CodeNoPods), want: 4},
+		{name: "forward failed", err: coded(k8sforward.CodeForwardFailed), want: 5},
+		{name: "local bind failed", err: coded(k8sforward.CodeLocalBindFailed), want: 5},
+		{name: "wrapped", err: fmt.Errorf("error starting port-forwarding: %w", coded(k8sforward.CodeNoPods)), want: 4},
+		{name: "uncoded", err: errors.New("failed"), want: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := exitCode(test.err); got != test.want {
+				t.Errorf("exitCode(%v) was %d, want %d", test.err, got, test.want)
+			}
+		})
+	}
+}
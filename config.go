@@ -0,0 +1,110 @@
+package k8sforward
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	bytesType    = reflect.TypeFor[[]byte]()
+)
+
+// LoadSettings reads the Settings of one or more forwards from the YAML or JSON file at path, which holds either a single
+// object or a list of objects, such as a team's standard set of tunnels. The keys are the names of Settings fields,
+// matched case-insensitively, such as 'contextName'. Durations are given as strings, such as '30s', and byte fields, such
+// as CAData, as text. Unknown keys are rejected, as are fields which cannot be given in a file, such as callbacks,
+// channels and streams. The Settings returned are not validated.
+func LoadSettings(path string) ([]*Settings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading settings file %s: %w", path, err)
+	}
+
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing settings file %s: %w", path, err)
+	}
+
+	var entries []map[string]json.RawMessage
+	if trimmed := bytes.TrimSpace(jsonData); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(jsonData, &entries)
+	} else {
+		var entry map[string]json.RawMessage
+		err = json.Unmarshal(jsonData, &entry)
+		entries = append(entries, entry)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("settings file %s must hold an object or a list of objects: %w", path, err)
+	}
+
+	settingsList := make([]*Settings, len(entries))
+	for i, entry := range entries {
+		if settingsList[i], err = settingsFromEntry(entry); err != nil {
+			return nil, fmt.Errorf("error in entry %d of settings file %s: %w", i+1, path, err)
+		}
+	}
+	return settingsList, nil
+}
+
+// settingsFromEntry returns the Settings with the fields named by the keys of entry set from their values.
+func settingsFromEntry(entry map[string]json.RawMessage) (*Settings, error) {
+	s := &Settings{}
+	settingsValue := reflect.ValueOf(s).Elem()
+
+	for key, raw := range entry {
+		field, ok := fileSettingsField(key)
+		if !ok {
+			return nil, fmt.Errorf("setting '%s' is unknown or cannot be given in a file", key)
+		}
+		fieldValue := settingsValue.FieldByIndex(field.Index)
+
+		switch field.Type {
+		case durationType:
+			var text string
+			if err := json.Unmarshal(raw, &text); err != nil {
+				return nil, fmt.Errorf("setting '%s' must be a duration string, such as '30s'", key)
+			}
+			duration, err := time.ParseDuration(text)
+			if err != nil {
+				return nil, fmt.Errorf("setting '%s' is not a valid duration: %w", key, err)
+			}
+			fieldValue.SetInt(int64(duration))
+		case bytesType:
+			var text string
+			if err := json.Unmarshal(raw, &text); err != nil {
+				return nil, fmt.Errorf("setting '%s' must be a string", key)
+			}
+			fieldValue.SetBytes([]byte(text))
+		default:
+			if err := json.Unmarshal(raw, fieldValue.Addr().Interface()); err != nil {
+				return nil, fmt.Errorf("setting '%s' is invalid: %w", key, err)
+			}
+		}
+	}
+	return s, nil
+}
+
+// fileSettingsField returns the field of Settings named key case-insensitively, if it can be given in a settings file.
+func fileSettingsField(key string) (reflect.StructField, bool) {
+	settingsType := reflect.TypeFor[Settings]()
+	for i := range settingsType.NumField() {
+		field := settingsType.Field(i)
+		if !field.IsExported() || !strings.EqualFold(field.Name, key) {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Func, reflect.Chan, reflect.Interface:
+			return reflect.StructField{}, false
+		}
+		return field, true
+	}
+	return reflect.StructField{}, false
+}
@@ -0,0 +1,77 @@
+package k8sforward
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadSettings(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Settings
+		wantErr bool
+	}{
+		{
+			name:    "single object",
+			content: "contextName: dev\nappName: web\nlocalAddress: localhost:8080\nremotePort: '80'\n",
+			want:    []Settings{{ContextName: "dev", AppName: "web", LocalAddress: "localhost:8080", RemotePort: "80"}},
+		},
+		{
+			name:    "list of objects",
+			content: "- contextName: dev\n  appName: web\n- contextname: prod\n  APPNAME: api\n",
+			want:    []Settings{{ContextName: "dev", AppName: "web"}, {ContextName: "prod", AppName: "api"}},
+		},
+		{
+			name:    "JSON",
+			content: `{"contextName": "dev", "appName": "web"}`,
+			want:    []Settings{{ContextName: "dev", AppName: "web"}},
+		},
+		{
+			name:    "duration and bytes",
+			content: "readyTimeout: 30s\ncaData: ca\n",
+			want:    []Settings{{ReadyTimeout: 30 * time.Second, CAData: []byte("ca")}},
+		},
+		{name: "unknown key", content: "contextName: dev\nunknown: true\n", wantErr: true},
+		{name: "callback", content: "podFilter: first\n", wantErr: true},
+		{name: "invalid duration", content: "readyTimeout: soon\n", wantErr: true},
+		{name: "duration as a number", content: "readyTimeout: 30\n", wantErr: true},
+		{name: "wrong type", content: "contextName: [dev]\n", wantErr: true},
+		{name: "scalar", content: "dev\n", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "forwards.yaml")
+			if err := os.WriteFile(path, []byte(test.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			settingsList, err := LoadSettings(path)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("LoadSettings returned no error, want one")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadSettings returned error: %v", err)
+			}
+			if len(settingsList) != len(test.want) {
+				t.Fatalf("LoadSettings returned %d settings, want %d", len(settingsList), len(test.want))
+			}
+			for i, s := range settingsList {
+				want := &test.want[i]
+				if s.ContextName != want.ContextName || s.AppName != want.AppName || s.LocalAddress != want.LocalAddress ||
+					s.RemotePort != want.RemotePort || s.ReadyTimeout != want.ReadyTimeout || string(s.CAData) != string(want.CAData) {
+					t.Errorf("settings %d were %+v, want %+v", i+1, s, want)
+				}
+			}
+		})
+	}
+
+	if _, err := LoadSettings(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("LoadSettings of a missing file returned no error, want one")
+	}
+}
@@ -0,0 +1,109 @@
+package k8sforward
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// readyEndpointPods returns the names of the pods which are ready endpoints in the EndpointSlices of the Service of s.Target,
+// so that they are receiving traffic for the Service.
+func (s *Settings) readyEndpointPods(ctx context.Context) (map[string]bool, error) {
+	endpointSlices, err := s.clientset.DiscoveryV1().EndpointSlices(s.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", discoveryv1.LabelServiceName, s.serviceName),
+	})
+	if apierrors.IsForbidden(err) {
+		return nil, fmt.Errorf("not permitted to list the endpoints of Service '%s', as the 'list' permission on 'endpointslices' is required: %w", s.serviceName, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error listing the endpoints of Service '%s': %w", s.serviceName, err)
+	}
+
+	podNames := map[string]bool{}
+	for _, slice := range endpointSlices.Items {
+		for _, endpoint := range slice.Endpoints {
+			// An unknown readiness is treated as ready, as by the consumers of EndpointSlices.
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+			if endpoint.TargetRef != nil && endpoint.TargetRef.Kind == "Pod" {
+				podNames[endpoint.TargetRef.Name] = true
+			}
+		}
+	}
+	return podNames, nil
+}
+
+// filterReadyEndpoints returns the pods which are ready endpoints of the Service of s.Target.
+func (s *Settings) filterReadyEndpoints(ctx context.Context, pods []corev1.Pod) ([]corev1.Pod, error) {
+	podNames, err := s.readyEndpointPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var endpoints []corev1.Pod
+	for _, pod := range pods {
+		if podNames[pod.Name] {
+			endpoints = append(endpoints, pod)
+		}
+	}
+	return endpoints, nil
+}
+
+// checkServiceMembership writes a warning to s.ErrOut if pod, selected by the Service of s.Target, is not a ready endpoint
+// of the Service, such as when a readiness gate is not passed, or if it also backs other Services, so that whether it is
+// the intended backend can be confirmed. Problems making the checks are reported with Verbose.
+func (s *Settings) checkServiceMembership(ctx context.Context, pod *corev1.Pod) {
+	if podNames, err := s.readyEndpointPods(ctx); err != nil {
+		s.verbosef("Could not check the endpoints of Service '%s': %v", s.serviceName, err)
+	} else if !podNames[pod.Name] {
+		_, _ = fmt.Fprintf(s.ErrOut, "Warning: pod '%s' is not a ready endpoint of Service '%s', so it may not be receiving the Service's traffic%s\n",
+			pod.Name, s.serviceName, unmetReadinessGates(pod))
+	}
+
+	services, err := s.clientset.CoreV1().Services(pod.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		s.verbosef("Could not list the Services of pod '%s': %v", pod.Name, err)
+		return
+	}
+	var others []string
+	for _, service := range services.Items {
+		if service.Name == s.serviceName || len(service.Spec.Selector) == 0 {
+			continue
+		}
+		if labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			others = append(others, service.Name)
+		}
+	}
+	if len(others) > 0 {
+		slices.Sort(others)
+		_, _ = fmt.Fprintf(s.ErrOut, "Warning: pod '%s' of Service '%s' also backs Services %s\n", pod.Name, s.serviceName, strings.Join(others, ", "))
+	}
+}
+
+// unmetReadinessGates describes the readiness gates of pod whose conditions are not true, or returns "" if there are none.
+func unmetReadinessGates(pod *corev1.Pod) string {
+	var unmet []string
+	for _, gate := range pod.Spec.ReadinessGates {
+		met := false
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == gate.ConditionType && condition.Status == corev1.ConditionTrue {
+				met = true
+			}
+		}
+		if !met {
+			unmet = append(unmet, string(gate.ConditionType))
+		}
+	}
+	if len(unmet) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (readiness gates not passed: %s)", strings.Join(unmet, ", "))
+}
@@ -0,0 +1,91 @@
+package k8sforward
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Code is a stable, machine-readable code for the cause of an Error.
+type Code string
+
+const (
+	// CodeInvalidSettings is the code of errors from validating the settings.
+	CodeInvalidSettings Code = "INVALID_SETTINGS"
+	// CodeKubeconfigInvalid is the code of errors from loading the kubeconfig or configuring a client from it.
+	CodeKubeconfigInvalid Code = "KUBECONFIG_INVALID"
+	// CodeContextNotFound is the code of errors from the k8s context not being in the kubeconfig.
+	CodeContextNotFound Code = "CONTEXT_NOT_FOUND"
+	// CodeClusterUnreachable is the code of errors from the k8s cluster not being reachable by Ping.
+	CodeClusterUnreachable Code = "CLUSTER_UNREACHABLE"
+	// CodeNoPods is the code of errors from no pods matching the settings.
+	CodeNoPods Code = "NO_PODS"
+	// CodeForbidden is the code of errors from the k8s user lacking an RBAC permission.
+	CodeForbidden Code = "FORBIDDEN"
+	// CodeLocalBindFailed is the code of errors from failing to listen on the local address or socket.
+	CodeLocalBindFailed Code = "LOCAL_BIND_FAILED"
+	// CodeForwardFailed is the code of any other error, such as from port-forwarding failing.
+	CodeForwardFailed Code = "FORWARD_FAILED"
+)
+
+// Error is an error returned by Init, with a Code for its cause.
+type Error struct {
+	Code Code
+	Err  error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode returns the Code of err if it is or wraps an Error, and otherwise an empty Code.
+func ErrorCode(err error) Code {
+	var codeErr *Error
+	if errors.As(err, &codeErr) {
+		return codeErr.Code
+	}
+	return ""
+}
+
+// withCode returns err as an Error, with the Code of its cause.
+func withCode(err error) error {
+	var codeErr *Error
+	if errors.As(err, &codeErr) {
+		return err
+	}
+
+	code := CodeForwardFailed
+	switch {
+	case errors.Is(err, errNoPods) || errors.Is(err, errNoReadyPods):
+		code = CodeNoPods
+	case apierrors.IsForbidden(err) || isForbiddenMessage(err):
+		code = CodeForbidden
+	case strings.Contains(err.Error(), "unable to listen on"):
+		// The port-forwarder only reports failing to listen on the local address in its message.
+		code = CodeLocalBindFailed
+	}
+	return &Error{Code: code, Err: err}
+}
+
+// isForbiddenMessage reports whether err describes a k8s Forbidden error. Errors from establishing port-forwarding
+// only carry the message of the underlying error, so the API server's message is matched instead of its type.
+func isForbiddenMessage(err error) bool {
+	return strings.Contains(err.Error(), " is forbidden: ")
+}
+
+// forwardError returns a port-forwarding error for the pod, explaining any missing RBAC permission.
+func (s *Settings) forwardError(podName string, err error) error {
+	switch {
+	case apierrors.IsForbidden(err):
+		return fmt.Errorf("not permitted to port-forward from %s to %s:%s on %s, as the 'get' permission on 'pods' is required: %w", s.LocalAddress, podName, s.RemotePort, s.ContextName, err)
+	case isForbiddenMessage(err):
+		return fmt.Errorf("not permitted to port-forward from %s to %s:%s on %s, as the 'create' permission on 'pods/portforward' is required: %w", s.LocalAddress, podName, s.RemotePort, s.ContextName, err)
+	}
+	return fmt.Errorf("error port-forwarding from %s to %s:%s on %s: %w", s.LocalAddress, podName, s.RemotePort, s.ContextName, err)
+}
@@ -0,0 +1,51 @@
+package k8sforward
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestWithCode(t *testing.T) {
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "web-a", errors.New("no RBAC policy matched"))
+
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{name: "no pods", err: errNoPods, want: CodeNoPods},
+		{name: "wrapped no pods", err: fmt.Errorf("%w for app 'web' in 'dev' context", errNoPods), want: CodeNoPods},
+		{name: "wrapped no ready pods", err: fmt.Errorf("waiting: %w", errNoReadyPods), want: CodeNoPods},
+		{name: "forbidden", err: forbidden, want: CodeForbidden},
+		{name: "wrapped forbidden", err: fmt.Errorf("error listing pods: %w", forbidden), want: CodeForbidden},
+		{name: "forbidden message", err: errors.New(`pods "web-a" is forbidden: User "dev" cannot create resource "pods/portforward"`), want: CodeForbidden},
+		{name: "unable to listen", err: fmt.Errorf("error port-forwarding: %w", errors.New("unable to listen on any of the requested ports")), want: CodeLocalBindFailed},
+		{name: "other error", err: errors.New("connection reset by peer"), want: CodeForwardFailed},
+		{name: "already coded", err: &Error{Code: CodeContextNotFound, Err: errNoPods}, want: CodeContextNotFound},
+		{name: "wrapped already coded", err: fmt.Errorf("error starting: %w", &Error{Code: CodeInvalidSettings, Err: errors.New("app name is required")}), want: CodeInvalidSettings},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := withCode(test.err)
+			if got := ErrorCode(err); got != test.want {
+				t.Errorf("withCode(%v) has code %s, want %s", test.err, got, test.want)
+			}
+			if !errors.Is(err, test.err) {
+				t.Errorf("withCode(%v) returned %v, which does not wrap the error", test.err, err)
+			}
+		})
+	}
+}
+
+func TestErrorCodeUncoded(t *testing.T) {
+	if got := ErrorCode(errors.New("uncoded")); got != "" {
+		t.Errorf("ErrorCode of an uncoded error was %s, want none", got)
+	}
+	if got := ErrorCode(nil); got != "" {
+		t.Errorf("ErrorCode of nil was %s, want none", got)
+	}
+}
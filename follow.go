@@ -0,0 +1,94 @@
+package k8sforward
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// latestRolloutPods returns the pods of pods from the latest rollout of a Deployment, by their pod-template-hash label:
+// those with the same hash as the newest ready pod, or as the newest pod if none are ready.
+func latestRolloutPods(pods []corev1.Pod) []corev1.Pod {
+	var newest *corev1.Pod
+	for i := range pods {
+		pod := &pods[i]
+		if newest == nil || (isPodReady(pod) && !isPodReady(newest)) ||
+			(isPodReady(pod) == isPodReady(newest) && pod.CreationTimestamp.After(newest.CreationTimestamp.Time)) {
+			newest = pod
+		}
+	}
+	if newest == nil {
+		return pods
+	}
+
+	hash := newest.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	var latest []corev1.Pod
+	for _, pod := range pods {
+		if pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey] == hash {
+			latest = append(latest, pod)
+		}
+	}
+	return latest
+}
+
+// followLatest watches the pods of s.DeploymentName while pod is forwarded to, and rebinds to the pods of a new rollout
+// once one of them is ready. It returns once ctx is done or it has rebound.
+func (s *Settings) followLatest(ctx context.Context, pod *corev1.Pod) {
+	hash := pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey]
+	// The first watch starts with the existing pods, in case a new rollout is already ready.
+	resourceVersion := ""
+
+	for {
+		watcher, err := s.streamClientset.CoreV1().Pods(pod.Namespace).Watch(ctx, metav1.ListOptions{
+			LabelSelector:   s.workloadSelector,
+			FieldSelector:   "status.phase=Running",
+			ResourceVersion: resourceVersion,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				_, _ = fmt.Fprintf(s.ErrOut, "Error watching the pods of Deployment %s: %v\n", s.DeploymentName, err)
+			}
+			return
+		}
+
+		watching := true
+		for watching {
+			select {
+			case event, ok := <-watcher.ResultChan():
+				if !ok {
+					// Watches are ended by the API server after a while, so watch again from the last version seen.
+					watching = false
+					break
+				}
+				if event.Type == watch.Error {
+					// The last version seen may have expired, so watch again from the existing pods.
+					resourceVersion = ""
+					continue
+				}
+				watched, ok := event.Object.(*corev1.Pod)
+				if !ok {
+					continue
+				}
+				resourceVersion = watched.ResourceVersion
+				if event.Type == watch.Deleted || !isPodReady(watched) || !watched.CreationTimestamp.After(pod.CreationTimestamp.Time) ||
+					watched.Labels[appsv1.DefaultDeploymentUniqueLabelKey] == hash {
+					continue
+				}
+				watcher.Stop()
+				_, _ = fmt.Fprintf(s.ErrOut, "Pod %s of a new rollout of Deployment %s is ready, so rebinding to it\n", watched.Name, s.DeploymentName)
+				if err := s.Rebind(ctx); err != nil && ctx.Err() == nil {
+					_, _ = fmt.Fprintf(s.ErrOut, "Error rebinding to the new rollout of Deployment %s: %v\n", s.DeploymentName, err)
+				}
+				return
+			case <-ctx.Done():
+				watcher.Stop()
+				return
+			}
+		}
+		watcher.Stop()
+	}
+}
@@ -0,0 +1,70 @@
+package k8sforward
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// rolloutPod returns a pod of the rollout with hash, created at created and ready if ready.
+func rolloutPod(name, hash string, created time.Time, ready bool) corev1.Pod {
+	pod := testPod(name, "web", created)
+	pod.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = hash
+	if !ready {
+		pod.Status.Conditions = nil
+	}
+	return *pod
+}
+
+func TestLatestRolloutPods(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		pods []corev1.Pod
+		want []string
+	}{
+		{
+			name: "no pods",
+		},
+		{
+			name: "newest ready rollout",
+			pods: []corev1.Pod{
+				rolloutPod("old-a", "old", now.Add(-time.Hour), true),
+				rolloutPod("new-a", "new", now, true),
+				rolloutPod("old-b", "old", now.Add(-time.Hour), true),
+				rolloutPod("new-b", "new", now.Add(-time.Minute), false),
+			},
+			want: []string{"new-a", "new-b"},
+		},
+		{
+			name: "ready rollout preferred over a newer one which is not ready",
+			pods: []corev1.Pod{
+				rolloutPod("old-a", "old", now.Add(-time.Hour), true),
+				rolloutPod("new-a", "new", now, false),
+			},
+			want: []string{"old-a"},
+		},
+		{
+			name: "newest rollout when none are ready",
+			pods: []corev1.Pod{
+				rolloutPod("old-a", "old", now.Add(-time.Hour), false),
+				rolloutPod("new-a", "new", now, false),
+			},
+			want: []string{"new-a"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, pod := range latestRolloutPods(test.pods) {
+				got = append(got, pod.Name)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("latestRolloutPods returned %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package k8sforward
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// awaitGRPCHealth polls the gRPC health service through the forward until it reports s.GRPCHealthService as serving,
+// or ctx is done.
+func (s *Settings) awaitGRPCHealth(ctx context.Context) error {
+	conn, err := grpc.NewClient(s.forwardAddress, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("error creating gRPC client for %s: %w", s.forwardAddress, err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	client := healthpb.NewHealthClient(conn)
+	request := &healthpb.HealthCheckRequest{Service: s.GRPCHealthService}
+
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, s.PollInterval)
+		response, err := client.Check(checkCtx, request)
+		cancel()
+		if err == nil && response.GetStatus() == healthpb.HealthCheckResponse_SERVING {
+			return nil
+		}
+		if err != nil {
+			s.verbosef("gRPC health check through %s failed: %v", s.forwardAddress, err)
+		} else {
+			s.verbosef("gRPC health check through %s reported %s", s.forwardAddress, response.GetStatus())
+		}
+
+		select {
+		case <-time.After(s.PollInterval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package k8sforward
+
+import (
+	"context"
+	"errors"
+)
+
+// Handle controls port-forwarding started by Start.
+type Handle struct {
+	ready  chan struct{}
+	done   chan error
+	cancel context.CancelFunc
+}
+
+// Start validates s and initiates port-forwarding in the background with the given Go context `ctx`, returning a Handle
+// to it. Port-forwarding runs as with Init until ctx is done, the Handle is stopped or it fails.
+// The Handle has its own ready channel, so s.ReadyChannel is neither required nor changed.
+func Start(ctx context.Context, s *Settings) (*Handle, error) {
+	if err := s.Validate(); err != nil {
+		return nil, &Error{Code: CodeInvalidSettings, Err: err}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	h := &Handle{
+		ready:  make(chan struct{}),
+		done:   make(chan error, 1),
+		cancel: cancel,
+	}
+
+	go func() {
+		defer cancel()
+		h.done <- s.initForward(ctx, h.ready)
+	}()
+
+	return h, nil
+}
+
+// Ready returns a channel which is closed once port-forwarding first becomes ready.
+func (h *Handle) Ready() <-chan struct{} {
+	return h.ready
+}
+
+// Done returns a channel which receives the result of port-forwarding once it has ended, as returned by Init.
+// It receives nil if port-forwarding was stopped.
+func (h *Handle) Done() <-chan error {
+	return h.done
+}
+
+// Stop stops port-forwarding. It does not wait for it to end, for which Done can be received from.
+func (h *Handle) Stop() {
+	h.cancel()
+}
+
+// WithForward initiates port-forwarding as Start does, waits for it to become ready, and calls fn with the local address.
+// Port-forwarding is stopped once fn returns or panics, and the error returned by fn, if any, is returned.
+func (s *Settings) WithForward(ctx context.Context, fn func(localAddress string) error) (err error) {
+	h, err := Start(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-h.Ready():
+	case err := <-h.Done():
+		if err == nil {
+			if err = ctx.Err(); err == nil {
+				err = errors.New("port-forwarding stopped before becoming ready")
+			}
+		}
+		return err
+	}
+
+	defer func() {
+		h.Stop()
+		if doneErr := <-h.Done(); err == nil {
+			err = doneErr
+		}
+	}()
+
+	return fn(s.LocalAddress)
+}
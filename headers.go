@@ -0,0 +1,25 @@
+package k8sforward
+
+import "net/http"
+
+// headerRoundTripper adds headers to each request before sending it with rt.
+type headerRoundTripper struct {
+	headers map[string]string
+	rt      http.RoundTripper
+}
+
+func (h *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given.
+	req = req.Clone(req.Context())
+	for key, value := range h.headers {
+		req.Header.Set(key, value)
+	}
+	return h.rt.RoundTrip(req)
+}
+
+// wrapHeaders returns a wrapper for round trippers adding headers to each request.
+func wrapHeaders(headers map[string]string) func(rt http.RoundTripper) http.RoundTripper {
+	return func(rt http.RoundTripper) http.RoundTripper {
+		return &headerRoundTripper{headers: headers, rt: rt}
+	}
+}
@@ -5,9 +5,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/runtime/serializer"
@@ -16,44 +24,359 @@ import (
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 	"k8s.io/kubectl/pkg/cmd/portforward"
 )
 
+const (
+	defaultAppLabelKey     = "app"
+	defaultVersionLabelKey = "version"
+)
+
+// errMaxDuration is the cause of the Go context being done when MaxDuration is reached.
+var errMaxDuration = errors.New("maximum port-forward duration reached")
+
+// errRunning is returned by methods which would reconfigure the k8s clients of Settings used by running port-forwarding.
+var errRunning = errors.New("cannot be called while port-forwarding with these Settings is running")
+
 type Settings struct {
-	// ContextName (required) is the k8s context to use.
+	// ContextName (required unless APIServer is given) is the k8s context to use. With APIServer, it defaults to APIServer,
+	// and only identifies the cluster in messages and events.
 	ContextName string
-	// AppName  (required) selects for pods with the label app='AppName'.
-	// If more than one pod is found, the first pod encountered is used.
+	// AppName  (required unless StatefulSetName, JobName, DeploymentName or AnnotationSelector is given) selects for pods with the label app='AppName', or AppLabelKey='AppName'.
+	// If more than one pod is found, the pod with the lowest name is used.
 	AppName string
-	// LocalAddress (required) is the local address to port-forward to.
+	// AppNamePrefix (optional). If true, AppName selects for pods with an app label starting with 'AppName'.
+	// An error listing the matching apps is returned if more than one app matches.
+	AppNamePrefix bool
+	// PodFilter (optional). If given, it chooses the pod to use from all the running pods matching the other settings.
+	// Defaults to FirstPod. It cannot be given with OnMultiplePods.
+	PodFilter PodFilter
+	// RequireSingleApp (optional). If true, an error listing the apps is returned if the matching pods are of more than one app,
+	// by their AppLabelKey labels, such as when a loose Selector matches several workloads.
+	RequireSingleApp bool
+	// PreferZone (optional). If given and more than one pod matches, ready pods on nodes in this topology zone, by the
+	// topology.kubernetes.io/zone node label, are preferred, falling back to any pod if there are none.
+	PreferZone string
+	// OnMultiplePods (optional). If given, it is called when more than one pod matches, and the pod with the name it returns is used.
+	// If it returns an error, port-forwarding is not started. If it is not given, the pod with the lowest name is used.
+	OnMultiplePods func(pods []PodInfo) (chosen string, err error)
+	// OnPodSelected (optional). If given, it is called with the selected pod each time one is selected, before port-forwarding to it begins.
+	OnPodSelected func(pod PodInfo)
+	// LocalAddress (required unless LocalSocketPath is given) is the local address to port-forward to.
 	LocalAddress string
+	// AllowPublicBind (optional). If true, LocalAddress may have the host 0.0.0.0, listening on all network interfaces,
+	// such as for sharing port-forwarding with a teammate. Anyone who can reach this machine can then connect through it,
+	// so a warning is written to ErrOut when it is used.
+	AllowPublicBind bool
+	// LocalSocketPath (optional). If given, connections to a Unix domain socket created at this path are relayed to LocalAddress.
+	// If LocalAddress is not given, a free TCP port on 127.0.0.1 is chosen for it.
+	LocalSocketPath string
+	// ListenBacklog (optional) is the listen backlog of the LocalSocketPath socket, for many connections made at once.
+	// Defaults to the system default. The TCP listener on LocalAddress is created by the port-forwarder with the system
+	// default backlog; on Unix-like systems it has SO_REUSEADDR set, so that it can be rebound while earlier connections
+	// are in TIME_WAIT.
+	ListenBacklog int
+	// DeferAccept (optional). If true, the port-forwarder listens on a free port on 127.0.0.1, and connections to LocalAddress
+	// (and LocalSocketPath if given) are relayed to it, but are not accepted until port-forwarding first becomes ready,
+	// including passing GRPCHealthCheck if set. Clients connecting early therefore wait in the listen backlog rather
+	// than failing. LocalAddress must then have a port other than 0.
+	DeferAccept bool
+	// Trace (optional). If true, the port-forwarder listens on a free port on 127.0.0.1, as with DeferAccept, and connections
+	// to LocalAddress (and LocalSocketPath if given) are relayed to it and counted, so that transfer statistics are available
+	// from TraceStats, such as for telling slow port-forwarding from a slow backend. Relaying adds some overhead of its own.
+	// LocalAddress must then have a port other than 0.
+	Trace bool
 	// RemotePort (required) is the port on the pod to port-forward from.
+	// A warning is written to ErrOut if the selected pod declares container ports which do not include it.
 	RemotePort string
-	// VersionName  (optional). If given this sub-selects for pods with the label version='VersionName'.
-	// If more than one pod is found, the first pod encountered is used.
+	// LocalAddressEnv (optional). If given, LocalAddress is read from the environment variable of this name by Validate,
+	// unless LocalAddress is given.
+	LocalAddressEnv string
+	// RemotePortEnv (optional). If given, RemotePort is read from the environment variable of this name by Validate,
+	// unless RemotePort is given.
+	RemotePortEnv string
+	// StrictPort (optional). If true, an error is returned instead if the selected pod does not declare RemotePort as a container port.
+	StrictPort bool
+	// CheckLocalPort (optional). If true, the local port is bound and released before connecting to the k8s cluster,
+	// so that a port already in use is reported before any port-forwarding is attempted.
+	CheckLocalPort bool
+	// VersionName  (optional). If given this sub-selects for pods with the label version='VersionName', or VersionLabelKey='VersionName'.
+	// It may be a comma-separated list of versions, such as 'v1,v2', to select for pods of any of them.
+	// If more than one pod is found, the pod with the lowest name is used.
 	VersionName string
-	// KubeconfigPath (optional). This overrides the path to the kubeconfig file from the default value of $HOME/.kube/config.
+	// AppLabelKey (optional) is the key of the label selected for by AppName. Defaults to "app".
+	// Set it to "app.kubernetes.io/name" for the recommended Kubernetes labels.
+	AppLabelKey string
+	// VersionLabelKey (optional) is the key of the label selected for by VersionName. Defaults to "version".
+	// Set it to "app.kubernetes.io/version" for the recommended Kubernetes labels.
+	VersionLabelKey string
+	// Target (optional). If given, pods are selected for by a resource in kubectl's kind/name format, instead of by AppName:
+	// a pod, such as 'pod/api-0', a Service by its selector, such as 'svc/api', or a Deployment, StatefulSet or Job as with
+	// DeploymentName, StatefulSetName and JobName. RemotePort is always a port of the pod, not of a Service.
+	Target string
+	// ReadyEndpointsOnly (optional). If true with a Service Target, only pods which are ready endpoints in the EndpointSlices
+	// of the Service are selected, so that the pod forwarded to is one receiving traffic for the Service.
+	ReadyEndpointsOnly bool
+	// CheckServiceMembership (optional). If true with a Service Target, a warning is written to ErrOut if the selected pod
+	// is not a ready endpoint of the Service, or if it also backs other Services, so that it can be confirmed to be the
+	// intended backend.
+	CheckServiceMembership bool
+	// Selector (optional). If given, pods are selected for by its label and field selectors alone, instead of by AppName,
+	// VersionName, AnnotationSelector, NodeName and FieldSelector, which are ignored. It cannot be given with a StatefulSet,
+	// Job or Deployment name.
+	Selector *PodSelector
+	// AnnotationSelector (optional). If given, only pods with all these annotations are selected for. Annotations cannot be
+	// selected for by the k8s API server, so pods are filtered after listing them, by AppName and VersionName if given, or else all pods.
+	AnnotationSelector map[string]string
+	// StatefulSetName (optional). If given, the pod of the StatefulSet with the given Ordinal is used instead of selecting by AppName,
+	// that is the pod named 'StatefulSetName-Ordinal' in the namespace of the k8s context.
+	StatefulSetName string
+	// Ordinal (optional) is the ordinal of the StatefulSet pod to use with StatefulSetName. Defaults to 0.
+	Ordinal int
+	// JobName (optional). If given, pods are selected for by the selector of this Job, in the namespace of the k8s context,
+	// instead of by AppName.
+	JobName string
+	// DeploymentName (optional). If given, pods are selected for by the selector of this Deployment, in the namespace of the k8s context,
+	// instead of by AppName.
+	DeploymentName string
+	// NodeName (optional). If given, only pods scheduled on this node are selected.
+	NodeName string
+	// MinPodAge (optional). If given, only pods which have been ready for at least this duration, or have existed for it
+	// if they have no ready condition, are selected, so that applications which are still starting are avoided.
+	MinPodAge time.Duration
+	// Pods (optional). If given, pods are selected from these, for instance as already listed by the caller, instead of
+	// being listed from the k8s API server. FieldSelector is not applied to them.
+	Pods []corev1.Pod
+	// FollowLatest (optional). If true with DeploymentName, pods of the latest rollout of the Deployment are selected, and
+	// port-forwarding is rebound, as with Rebind, to a pod of any new rollout once it is ready.
+	FollowLatest bool
+	// FieldSelector (optional). If given this is appended to the default field selector of status.phase=Running.
+	// It must not itself select on status.phase unless IncludeNonRunning is given.
+	FieldSelector string
+	// IncludeNonRunning (optional). If true, pods are selected whatever their phase, rather than only running pods, such as
+	// for attaching to a crash-looping pod which is only transiently running. A warning is written to ErrOut when the pod
+	// selected is not running, as port-forwarding to it will likely fail.
+	IncludeNonRunning bool
+	// ListLimit (optional). If given, pods are listed in pages of this size, and selection is made from the first page
+	// with any matching pods rather than from all matching pods. This reduces the load on the k8s API server when there are many pods.
+	ListLimit int64
+	// AllNamespaces (optional). If true, pods are selected from all namespaces rather than the namespace of the k8s context.
+	AllNamespaces bool
+	// Namespace (optional). If given, pods are selected from this namespace rather than the namespace of the k8s context.
+	// It cannot be given with AllNamespaces.
+	Namespace string
+	// APIServer (optional). If given, this URL of the k8s API server is used without any kubeconfig, authenticating with
+	// ClientCertData and ClientKeyData or BearerToken or BearerTokenFile if given, and verifying the server with CAData if given. Pods are selected from
+	// Namespace, or otherwise the default namespace. KubeconfigPath, AuthInfoOverride and ClusterOverride cannot then be given.
+	APIServer string
+	// KubeconfigPath (optional). This overrides the path to the kubeconfig file. By default, as with kubectl, the files listed
+	// in the KUBECONFIG environment variable are merged, or if it is not set, $HOME/.kube/config is used.
 	KubeconfigPath string
+	// AuthInfoOverride (optional). If given, this kubeconfig user is used instead of the user of the k8s context, as with
+	// kubectl's --user flag.
+	AuthInfoOverride string
+	// ClusterOverride (optional). If given, this kubeconfig cluster is used instead of the cluster of the k8s context, as
+	// with kubectl's --cluster flag.
+	ClusterOverride string
+	// ProxyURL (optional). If given, requests to the k8s API server, including for port-forwarding, are made through this
+	// http, https or socks5 proxy, overriding any proxy in the kubeconfig or environment.
+	ProxyURL string
+	// InsecureSkipTLSVerify (optional). If true, the certificate of the k8s API server is not verified, which is insecure
+	// and should only be used with development clusters. A warning is written to ErrOut when it is used.
+	InsecureSkipTLSVerify bool
+	// TLSServerName (optional). If given, it is the server name used to verify the certificate of the k8s API server,
+	// instead of the host of the server.
+	TLSServerName string
+	// ClientCertData (optional). If given with ClientKeyData, it is the PEM-encoded client certificate used to authenticate
+	// to the k8s API server, instead of any given by the kubeconfig, such as for ephemeral credentials given at runtime.
+	ClientCertData []byte
+	// ClientKeyData (optional) is the PEM-encoded private key of ClientCertData, with which it must be given.
+	ClientKeyData []byte
+	// BearerToken (optional). If given, it is the bearer token used to authenticate to the k8s API server, instead of any
+	// credentials given by the kubeconfig other than a client certificate, such as for automation with short-lived tokens.
+	BearerToken string
+	// BearerTokenFile (optional). If given, the bearer token is read from this file, as with BearerToken, and is re-read
+	// periodically so that a rotated token is used. It cannot be given with BearerToken.
+	BearerTokenFile string
+	// CAData (optional). If given, it is the PEM-encoded certificate authority bundle used to verify the certificate of the
+	// k8s API server, instead of any given by the kubeconfig. It cannot be given with InsecureSkipTLSVerify.
+	CAData []byte
+	// Impersonate (optional). If given, requests to the k8s API server, including for port-forwarding, are made as this user.
+	Impersonate string
+	// ImpersonateGroups (optional). If given with Impersonate, requests are made as a member of these groups.
+	ImpersonateGroups []string
+	// UserAgent (optional) is the User-Agent of requests to the k8s API server. Defaults to "k8sforward/<Version()>".
+	UserAgent string
+	// ExtraHeaders (optional). If given, these headers are added to every request to the k8s API server, including
+	// for port-forwarding, such as for an API gateway in front of it.
+	ExtraHeaders map[string]string
+	// QPS (optional) is the maximum rate of requests per second to the k8s API server. Defaults to the client-go default.
+	QPS float32
+	// Burst (optional) is the maximum burst of requests to the k8s API server. Defaults to the client-go default.
+	Burst int
+	// RequestTimeout (optional). If given, each request to the k8s API server, such as for listing pods, times out after
+	// this duration. It does not apply to port-forwarding itself, or to watching pods or streaming logs, which are long-lived.
+	RequestTimeout time.Duration
+	// Transport (optional) is how port-forwarding connects to the pod: TransportAuto (the default), TransportSPDY or
+	// TransportWebSocket. Some proxies break SPDY but allow WebSocket.
+	Transport string
 	// ReadyChannel (optional). If ReadyChannel is specified, the commencement of port-forwarding can be detected by receiving from it.
+	// It is closed only once, so if the Settings are used again, a new ReadyChannel must be given to detect the commencement of
+	// the later port-forwarding.
 	ReadyChannel chan struct{}
+	// StopChannel (optional). If StopChannel is specified, port-forwarding is stopped, as on cancelling the Go context, when
+	// it is closed. It is never closed by k8sforward, so one StopChannel can be shared between several forwards.
+	StopChannel chan struct{}
+	// GRPCHealthCheck (optional). If true, port-forwarding is not treated as ready until a gRPC health check through it,
+	// using the grpc.health.v1.Health/Check method, reports GRPCHealthService as serving. The check is retried every PollInterval.
+	// LocalAddress must then have a port other than 0.
+	GRPCHealthCheck bool
+	// GRPCHealthService (optional) is the service name for GRPCHealthCheck. Defaults to "", meaning the overall server health.
+	GRPCHealthService string
+	// MaxDuration (optional). If given, port-forwarding is stopped after this duration, and Init returns nil as with cancellation.
+	MaxDuration time.Duration
+	// WaitForReadyPod (optional). If given, port-forwarding waits for at most this duration for a matching pod to exist
+	// and become ready, returning an error if none does. Ready pods are then preferred when selecting among matching pods.
+	WaitForReadyPod time.Duration
+	// PollInterval (optional) is the interval between polls while waiting, such as for GRPCHealthCheck, or for
+	// WaitForReadyPod if watching pods fails. Defaults to 1s.
+	PollInterval time.Duration
+	// ReadyTimeout (optional). If given, an error is returned if port-forwarding does not become ready within this duration.
+	ReadyTimeout time.Duration
+	// KeepAlive (optional). If given, a connection is opened and immediately closed through the forward at this interval
+	// once it is ready, so that idle tunnels are not dropped by intermediate proxies or NATs.
+	// Note that each keepalive is seen by the remote port as a new connection.
+	// LocalAddress must then have a port other than 0.
+	KeepAlive time.Duration
+	// StopTimeout (optional). If given, once stopped port-forwarding is waited for for at most this duration to shut down,
+	// after which its local listener is closed and it is abandoned with a warning, so that stopping cannot block on a wedged
+	// connection. An abandoned port-forward's goroutine remains until its connection to the pod is released.
+	StopTimeout time.Duration
+	// DrainTimeout (optional). If given with LocalSocketPath, DeferAccept or Trace, once stopped, new connections are refused and
+	// port-forwarding is kept open for at most this duration for the relayed connections to finish, such as long
+	// downloads. Connections made directly to the port-forwarder cannot be drained, as it closes them on stopping.
+	// Port-forwarding is stopped by cancelling the Go context or closing StopChannel. While draining is configured,
+	// k8sforward does not itself stop on an interrupt signal, so to drain on Ctrl+C, cancel the Go context on it, such as
+	// with signal.NotifyContext.
+	DrainTimeout time.Duration
+	// Reconnect (optional). If true, once port-forwarding has been established it is re-established, with a newly selected pod,
+	// whenever it ends with an error. Reconnection attempts are delayed by an exponential backoff with jitter.
+	// Each reconnection authenticates afresh, so credentials from exec plugins that have expired during a long session are refreshed.
+	Reconnect bool
+	// OnReconnect (optional). If given with Reconnect or RetryInitial, it is called before each reconnection attempt with
+	// the number of the attempt since port-forwarding was last established, starting at 1, and the error which ended it.
+	// If it returns false, reconnection is abandoned and the error is returned, so that the retry policy can be controlled.
+	OnReconnect func(attempt int, lastErr error) (proceed bool)
+	// RetryInitial (optional). If true, port-forwarding is re-established, as with Reconnect, when it fails before first becoming ready,
+	// for instance because no pod is running yet or the pod is not yet serving.
+	RetryInitial bool
+	// ReconnectBackoffBase (optional) is the initial delay before reconnecting. Defaults to 1s.
+	// The delay is reset to this after a reconnection succeeds in establishing port-forwarding.
+	ReconnectBackoffBase time.Duration
+	// ReconnectBackoffMax (optional) is the maximum delay before reconnecting. Defaults to 30s.
+	ReconnectBackoffMax time.Duration
+	// ReconnectBackoffFactor (optional) multiplies the delay after each failed reconnection attempt. Defaults to 2.
+	// Each actual delay is chosen randomly between half and all of the current delay.
+	ReconnectBackoffFactor float64
+	// RotateOnNotReady (optional). If true, the pod forwarded to is watched, and port-forwarding is rebound, as with Rebind,
+	// to another ready pod, if there is one, once the pod has not been ready for NotReadyThreshold. Ready pods are also
+	// preferred when selecting among matching pods.
+	RotateOnNotReady bool
+	// NotReadyThreshold (optional) is how long the pod forwarded to may not be ready for with RotateOnNotReady. Defaults to 10s.
+	NotReadyThreshold time.Duration
 	// CancelFn (optional). If CancelFn is specified, it will be called upon any error except context.Canceled.
 	CancelFn context.CancelFunc
+	// In is the data stream for input to the port-forwarder (optional). Defaults to an empty reader, as port-forwarding
+	// does not read input.
+	In io.Reader
 	// Out is the data stream for output (optional). Defaults to os.Stdout.
+	// Lifecycle events and the port-forwarder's informational messages, such as handled connections, are written to it.
+	// To share a stream other than the default between concurrent forwards, wrap it once with NewSyncWriter.
 	Out io.Writer
 	// ErrOut is the data stream for error output (optional). Defaults to os.Stderr.
+	// Errors that do not end port-forwarding, such as failed connections, and Verbose messages are written to it. It can be silenced
+	// independently of Out, for instance with io.Discard. Errors that end port-forwarding are returned by Init regardless.
 	ErrOut io.Writer
+	// StreamLogs (optional). If true, the logs of each selected pod are followed and written to LogOut while forwarding to it.
+	StreamLogs bool
+	// LogOut is the data stream for pod logs with StreamLogs (optional). Defaults to ErrOut.
+	LogOut io.Writer
+	// ShowEquivalentCommand (optional). If true, the kubectl command equivalent to each port-forward is written to ErrOut
+	// once its pod is selected. It is also written with Verbose.
+	ShowEquivalentCommand bool
+	// Verbose (optional). If true, diagnostic messages, such as the cluster of the k8s context, are written to ErrOut.
+	Verbose bool
+	// Events (optional). If given, lifecycle events are sent on it. Sends do not block, so events are dropped
+	// if it is not ready to receive them, for instance if it is unbuffered and not being received from.
+	Events chan<- Event
+	// OutputFormat (optional) is the format of lifecycle events written to Out: OutputFormatText (the default) or OutputFormatJSON.
+	// With OutputFormatJSON, each event is written as a single-line JSON object and the port-forwarder's own prose output is suppressed.
+	OutputFormat string
 
-	localHost string
-	localPort string
-	validated bool
+	localHost        string
+	localPort        string
+	forwardAddress   string
+	firstReady       chan struct{}
+	trace            traceCounters
+	proxyURL         *url.URL
+	validated        bool
+	active           atomic.Bool
+	running          atomic.Bool
+	ready            atomic.Bool
+	currentPod       atomic.Pointer[PodInfo]
+	readyOnce        *sync.Once
+	closedReady      chan struct{}
+	rebind           chan chan struct{}
+	rebindOnce       sync.Once
+	previousPod      string
+	uptime           time.Duration
+	uptimePod        string
+	versionNames     []string
+	workloadSelector string
+	targetApplied    bool
+	podName          string
+	serviceName      string
+	namespace        string
+	restConfig       *rest.Config
+	clientset        kubernetes.Interface
+	streamClientset  kubernetes.Interface
 }
 
-// Init initiates port-forwarding with the given Go context `ctx`.
+// Init initiates port-forwarding with the given Go context `ctx`. It returns nil once ctx is cancelled or its deadline
+// is exceeded.
+// Any error returned is an *Error, with a Code for its cause.
 func Init(ctx context.Context, s *Settings) error {
-	if err := s.run(ctx); err != nil {
+	return s.initForward(ctx, nil)
+}
+
+// initForward is Init, additionally closing ready, if given, once port-forwarding first becomes ready.
+func (s *Settings) initForward(ctx context.Context, ready chan<- struct{}) error {
+	defer func() {
+		stopped := s.newEvent(EventStopped)
+		var text string
+		if s.uptime > 0 {
+			stopped.Pod = s.uptimePod
+			stopped.Uptime = s.uptime
+			text = fmt.Sprintf("Port-forward to %s ran for %s", s.uptimePod, s.uptime.Round(time.Second))
+		}
+		_ = s.writeEvent(stopped, text)
+	}()
+
+	started := time.Now()
+	if err := s.run(ctx, ready); err != nil {
 		if errors.Is(err, context.Canceled) {
 			return nil
 		}
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			// Reaching the deadline of ctx stops port-forwarding cleanly, as with cancellation, whatever error it caused.
+			s.verbosef("Port-forwarding stopped as the context deadline was exceeded after %s", time.Since(started).Round(time.Millisecond))
+			return nil
+		}
+		err = withCode(err)
+		errorEvent := s.newEvent(EventError)
+		errorEvent.Err = err
+		_ = s.writeEvent(errorEvent, "")
 		if s.CancelFn != nil {
 			s.CancelFn()
 		}
@@ -62,151 +385,884 @@ func Init(ctx context.Context, s *Settings) error {
 	return nil
 }
 
+// Validate checks the settings and fills in defaults, returning the first problem found. It is called by Init, and
+// need only be called beforehand to check the settings without initiating port-forwarding.
 func (s *Settings) Validate() error {
+	if errs := s.ValidateAll(); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ValidateAll checks the settings and fills in defaults as Validate does, but returns every problem found rather than
+// only the first, so that they can all be presented at once.
+func (s *Settings) ValidateAll() []error {
 	if s.validated {
 		return nil
 	}
 
+	var errs []error
+
+	if s.APIServer != "" {
+		if err := validateAPIServer(s.APIServer, s.KubeconfigPath, s.AuthInfoOverride, s.ClusterOverride); err != nil {
+			errs = append(errs, err)
+		}
+		if s.ContextName == "" {
+			s.ContextName = s.APIServer
+		}
+	}
 	if err := validateNonEmptyString("k8s context name", s.ContextName); err != nil {
-		return err
+		errs = append(errs, err)
+	}
+	if s.Namespace != "" {
+		if s.AllNamespaces {
+			errs = append(errs, errors.New("namespace and all namespaces cannot both be given"))
+		} else if err := validateNamespace(s.Namespace); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	if err := validateNonEmptyString("k8s app name", s.AppName); err != nil {
-		return err
+	if s.PodFilter != nil && s.OnMultiplePods != nil {
+		errs = append(errs, fmt.Errorf("pod filter and multiple pods callback cannot both be given"))
 	}
 
-	addressParts, err := validateLocalAddress(s.LocalAddress)
-	if err != nil {
-		return err
+	if err := validateAnnotationSelector(s.AnnotationSelector); err != nil {
+		errs = append(errs, err)
 	}
-	s.localHost = addressParts[0]
-	s.localPort = addressParts[1]
 
-	if err := validateTCPPort("remote TCP port", s.RemotePort); err != nil {
-		return err
+	if s.Target != "" {
+		if err := s.applyTarget(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.ReadyEndpointsOnly && s.serviceName == "" {
+		errs = append(errs, errors.New("ready endpoints only requires a Service target"))
+	}
+	if s.CheckServiceMembership && s.serviceName == "" {
+		errs = append(errs, errors.New("checking Service membership requires a Service target"))
+	}
+
+	if err := validateSingleWorkload(s.StatefulSetName, s.JobName, s.DeploymentName); err != nil {
+		errs = append(errs, err)
+	}
+	if s.FollowLatest && s.DeploymentName == "" {
+		errs = append(errs, fmt.Errorf("following the latest rollout requires a Deployment name"))
+	}
+	if s.Selector != nil && (s.StatefulSetName != "" || s.JobName != "" || s.DeploymentName != "") {
+		errs = append(errs, fmt.Errorf("selector cannot be given with a StatefulSet, Job or Deployment name"))
+	}
+
+	switch {
+	case s.StatefulSetName != "":
+		if err := validateStatefulSetPod(s.AppName, s.AllNamespaces, s.Ordinal); err != nil {
+			errs = append(errs, err)
+		}
+	case s.JobName != "":
+		if err := validateWorkload("Job", s.AppName, s.AllNamespaces); err != nil {
+			errs = append(errs, err)
+		}
+	case s.DeploymentName != "":
+		if err := validateWorkload("Deployment", s.AppName, s.AllNamespaces); err != nil {
+			errs = append(errs, err)
+		}
+	case s.podName != "" || s.serviceName != "":
+		if s.AllNamespaces {
+			// A pod or Service target is found in the k8s context namespace, so pods of other namespaces must not be selected.
+			errs = append(errs, fmt.Errorf("all namespaces cannot be used with a pod or Service target"))
+		}
+	case s.Selector != nil:
+		if err := validatePodSelector(s.Selector, s.IncludeNonRunning); err != nil {
+			errs = append(errs, err)
+		}
+	case len(s.AnnotationSelector) == 0:
+		if err := validateNonEmptyString("k8s app name", s.AppName); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if s.AppName != "" {
+		if err := validateAppName(s.AppName, s.AppNamePrefix); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if s.LocalAddress == "" && s.LocalAddressEnv != "" {
+		if s.LocalAddress = os.Getenv(s.LocalAddressEnv); s.LocalAddress == "" {
+			errs = append(errs, fmt.Errorf("environment variable %s for the local address is not set", s.LocalAddressEnv))
+		}
+	}
+	if s.RemotePort == "" && s.RemotePortEnv != "" {
+		if s.RemotePort = os.Getenv(s.RemotePortEnv); s.RemotePort == "" {
+			errs = append(errs, fmt.Errorf("environment variable %s for the remote port is not set", s.RemotePortEnv))
+		}
+	}
+
+	if s.ListenBacklog < 0 {
+		errs = append(errs, fmt.Errorf("listen backlog must not be negative but was %d", s.ListenBacklog))
 	}
 
-	if s.KubeconfigPath == "" {
-		homeDir, ok := os.LookupEnv("HOME")
-		if !ok {
-			return fmt.Errorf("cannot resolve home directory")
+	if s.LocalSocketPath != "" && s.LocalAddress == "" {
+		if localAddress, err := freeLocalAddress(); err != nil {
+			errs = append(errs, err)
+		} else {
+			s.LocalAddress = localAddress
+		}
+	}
+
+	if addressParts, err := validateLocalAddress(s.LocalAddress, s.AllowPublicBind); err != nil {
+		errs = append(errs, err)
+	} else {
+		s.localHost = addressParts[0]
+		s.localPort = addressParts[1]
+	}
+
+	if s.GRPCHealthCheck && s.localPort == "0" {
+		// The health check connects through the forward, so needs to know its local port.
+		errs = append(errs, errors.New("a local port other than 0 is required with GRPCHealthCheck"))
+	}
+
+	s.forwardAddress = s.LocalAddress
+	if s.relaying() {
+		if s.localPort == "0" {
+			errs = append(errs, errors.New("a local port other than 0 is required with DeferAccept or Trace"))
+		}
+		if forwardAddress, err := freeLocalAddress(); err != nil {
+			errs = append(errs, err)
+		} else {
+			s.forwardAddress = forwardAddress
+		}
+	}
+
+	if err := validateTCPPort("remote TCP port", s.RemotePort, 1); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.AppLabelKey == "" {
+		s.AppLabelKey = defaultAppLabelKey
+	}
+	if err := validateLabelKey("app label key", s.AppLabelKey); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.VersionLabelKey == "" {
+		s.VersionLabelKey = defaultVersionLabelKey
+	}
+	if err := validateLabelKey("version label key", s.VersionLabelKey); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.VersionName != "" {
+		if versionNames, err := validateVersionNames(s.VersionName); err != nil {
+			errs = append(errs, err)
+		} else {
+			s.versionNames = versionNames
 		}
-		s.KubeconfigPath = filepath.Join(homeDir, ".kube", "config")
+	}
+
+	if err := validateFieldSelector(s.FieldSelector, s.IncludeNonRunning); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.ProxyURL != "" {
+		if proxyURL, err := validateProxyURL(s.ProxyURL); err != nil {
+			errs = append(errs, err)
+		} else {
+			s.proxyURL = proxyURL
+		}
+	}
+	if s.Impersonate == "" && len(s.ImpersonateGroups) > 0 {
+		errs = append(errs, fmt.Errorf("impersonated groups require an impersonated user"))
+	}
+
+	if s.UserAgent == "" {
+		s.UserAgent = fmt.Sprintf("k8sforward/%s", Version())
+	}
+
+	if err := validateRateLimits(s.QPS, s.Burst); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateTLSData(s.ClientCertData, s.ClientKeyData, s.CAData, s.InsecureSkipTLSVerify); err != nil {
+		errs = append(errs, err)
+	}
+	if s.BearerToken != "" && s.BearerTokenFile != "" {
+		errs = append(errs, errors.New("bearer token and bearer token file cannot both be given"))
+	}
+
+	if s.Transport == "" {
+		s.Transport = TransportAuto
+	}
+	if err := validateTransport(s.Transport); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.ListLimit < 0 {
+		errs = append(errs, fmt.Errorf("list limit must not be negative but was %d", s.ListLimit))
+	}
+
+	if err := validateNonNegativeDuration("maximum duration", s.MaxDuration); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateNonNegativeDuration("minimum pod age", s.MinPodAge); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateNonNegativeDuration("ready pod wait", s.WaitForReadyPod); err != nil {
+		errs = append(errs, err)
+	}
+	if s.PollInterval == 0 {
+		s.PollInterval = defaultPollInterval
+	}
+	if err := validateNonNegativeDuration("poll interval", s.PollInterval); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateNonNegativeDuration("ready timeout", s.ReadyTimeout); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateNonNegativeDuration("keepalive interval", s.KeepAlive); err != nil {
+		errs = append(errs, err)
+	} else if s.KeepAlive > 0 && s.localPort == "0" {
+		// Keepalive connections are made through the forward, so need to know its local port.
+		errs = append(errs, errors.New("a local port other than 0 is required with KeepAlive"))
+	}
+	if err := validateNonNegativeDuration("request timeout", s.RequestTimeout); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateNonNegativeDuration("stop timeout", s.StopTimeout); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateNonNegativeDuration("drain timeout", s.DrainTimeout); err != nil {
+		errs = append(errs, err)
+	}
+	if s.NotReadyThreshold == 0 {
+		s.NotReadyThreshold = defaultNotReadyThreshold
+	}
+	if err := validateNonNegativeDuration("not ready threshold", s.NotReadyThreshold); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.ReconnectBackoffBase == 0 {
+		s.ReconnectBackoffBase = defaultReconnectBackoffBase
+	}
+	if s.ReconnectBackoffMax == 0 {
+		s.ReconnectBackoffMax = defaultReconnectBackoffMax
+	}
+	if s.ReconnectBackoffFactor == 0 {
+		s.ReconnectBackoffFactor = defaultReconnectBackoffFactor
+	}
+	if err := validateReconnectBackoff(s.ReconnectBackoffBase, s.ReconnectBackoffMax, s.ReconnectBackoffFactor); err != nil {
+		errs = append(errs, err)
+	}
+
+	if s.In == nil {
+		s.In = strings.NewReader("")
 	}
 
 	if s.Out == nil {
-		s.Out = os.Stdout
+		s.Out = stdout
 	}
 
 	if s.ErrOut == nil {
-		s.ErrOut = os.Stderr
+		s.ErrOut = stderr
 	}
 
-	s.validated = true
+	if s.LogOut == nil {
+		s.LogOut = s.ErrOut
+	}
 
-	return nil
+	if s.OutputFormat == "" {
+		s.OutputFormat = OutputFormatText
+	}
+	if err := validateOutputFormat(s.OutputFormat); err != nil {
+		errs = append(errs, err)
+	}
+
+	s.validated = len(errs) == 0
+
+	return errs
 }
 
-func (s *Settings) run(ctx context.Context) error {
-	if err := s.Validate(); err != nil {
+// LocalHost returns the host part of LocalAddress. It is empty until Validate has succeeded.
+func (s *Settings) LocalHost() string {
+	return s.localHost
+}
+
+// LocalPort returns the port part of LocalAddress. It is empty until Validate has succeeded.
+func (s *Settings) LocalPort() string {
+	return s.localPort
+}
+
+// IsActive reports whether port-forwarding is currently running, whether or not it has become ready.
+// It is false while waiting to reconnect.
+func (s *Settings) IsActive() bool {
+	return s.active.Load()
+}
+
+// IsReady reports whether port-forwarding is currently ready to accept connections.
+// It becomes false once port-forwarding is disconnected or stopped.
+func (s *Settings) IsReady() bool {
+	return s.ready.Load()
+}
+
+func (s *Settings) run(ctx context.Context, ready chan<- struct{}) error {
+	s.running.Store(true)
+	defer s.running.Store(false)
+
+	// The stopped event reports the uptime of this run only.
+	s.uptime, s.uptimePod = 0, ""
+
+	if err := s.prepare(); err != nil {
 		return err
 	}
+	// firstReady and readyOnce belong to this run, so that a later run with the same Settings signals its own readiness,
+	// through a Handle or a new ReadyChannel. A ReadyChannel closed by an earlier run is left as it is.
+	s.firstReady = make(chan struct{})
+	s.readyOnce = &sync.Once{}
+
+	if ready != nil {
+		firstReady, done := s.firstReady, make(chan struct{})
+		defer close(done)
+		go func() {
+			select {
+			case <-firstReady:
+				close(ready)
+			case <-done:
+			}
+		}()
+	}
+
+	if ip := net.ParseIP(s.localHost); ip != nil && ip.IsUnspecified() {
+		_, _ = fmt.Fprintf(s.ErrOut, "Warning: port-forwarding from %s listens on all network interfaces, so anyone who can reach this machine can connect through it\n", s.LocalAddress)
+	}
+
+	register(s)
+	defer unregister(s)
+
+	// Privileged ports are always checked, as the port-forwarder does not report why it cannot bind them.
+	if s.CheckLocalPort || isPrivilegedPort(s.localPort) {
+		if err := s.checkLocalPort(); err != nil {
+			return err
+		}
+	}
+
+	if s.StopChannel != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-s.StopChannel:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+	}
+
+	if s.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, s.MaxDuration, errMaxDuration)
+		defer cancel()
+	}
 
-	apiConfig, err := clientcmd.LoadFromFile(s.KubeconfigPath)
+	var listeners []net.Listener
+	if s.LocalSocketPath != "" {
+		listener, err := s.listenUnix()
+		if err != nil {
+			return err
+		}
+		listeners = append(listeners, listener)
+	}
+	if s.relaying() {
+		listener, err := s.listenRelayed()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	forwardCtx := ctx
+	if len(listeners) > 0 {
+		relayCtx, cancelRelay := context.WithCancel(ctx)
+		defer cancelRelay()
+
+		cancelForward := func() {}
+		if s.DrainTimeout > 0 {
+			// Port-forwarding outlives ctx until the relayed connections have drained.
+			forwardCtx, cancelForward = context.WithCancel(context.WithoutCancel(ctx))
+			defer cancelForward()
+		}
+
+		go func() {
+			var relays sync.WaitGroup
+			var listening sync.WaitGroup
+			for _, listener := range listeners {
+				listening.Add(1)
+				go func() {
+					defer listening.Done()
+					s.relayListener(relayCtx, listener, &relays)
+				}()
+			}
+			listening.Wait()
+			if s.DrainTimeout > 0 {
+				// No more relays are added once relayListener has returned.
+				<-relayCtx.Done()
+				s.drain(&relays)
+				cancelForward()
+			}
+		}()
+	}
+
+	err := s.runForwarding(forwardCtx)
+	if errors.Is(context.Cause(ctx), errMaxDuration) {
+		// Reaching MaxDuration stops port-forwarding cleanly, as with cancellation.
+		return nil
+	}
+	return err
+}
+
+// runForwarding port-forwards until ctx is done or forwarding fails, reconnecting and rebinding as required.
+func (s *Settings) runForwarding(ctx context.Context) error {
+	if s.Reconnect || s.RetryInitial {
+		return s.reconnect(ctx)
+	}
+
+	for {
+		_, err := s.forward(ctx)
+		if !errors.Is(err, errRebind) {
+			return err
+		}
+	}
+}
+
+// forward selects a pod and port-forwards to it until ctx is done or forwarding fails.
+// It reports whether port-forwarding was established.
+func (s *Settings) forward(ctx context.Context) (bool, error) {
+	// runPortForward closes the StopChannel once its context is done, so cancelling on every return
+	// releases the local listener and any goroutines below. The StopChannel must not also be closed here,
+	// as that would race with the close in runPortForward.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if _, err := s.workloadLabelSelector(ctx); err != nil {
+		return false, err
+	}
+
+	if s.WaitForReadyPod > 0 {
+		if err := s.waitForReadyPod(ctx, s.streamClientset.CoreV1()); err != nil {
+			return false, s.explainNoPods(ctx, err)
+		}
+	}
+
+	pod, selection, err := s.selectPod(ctx, s.clientset.CoreV1())
 	if err != nil {
-		return fmt.Errorf("error loading the k8s config from %s: %w", s.KubeconfigPath, err)
+		return false, s.explainNoPods(ctx, err)
 	}
 
-	k8sCtx, ok := apiConfig.Contexts[s.ContextName]
-	if !ok {
-		return fmt.Errorf("unknown k8s context '%s'", s.ContextName)
+	if err = s.checkRemotePort(pod); err != nil {
+		return false, err
 	}
 
-	clientConfig := clientcmd.NewDefaultClientConfig(*apiConfig, &clientcmd.ConfigOverrides{
-		CurrentContext: s.ContextName,
-	})
+	if pod.Status.Phase != corev1.PodRunning {
+		_, _ = fmt.Fprintf(s.ErrOut, "Warning: pod '%s' is %s rather than Running, so port-forwarding to it will likely fail\n", pod.Name, pod.Status.Phase)
+	}
+
+	if s.CheckServiceMembership {
+		s.checkServiceMembership(ctx, pod)
+	}
 
-	restConfig, err := clientConfig.ClientConfig()
+	portForwardOptions, err := s.portForwardOptions(pod)
 	if err != nil {
-		return fmt.Errorf("error creating the k8s client REST config: %w", err)
+		return false, err
+	}
+	forwarder := portForwardOptions.PortForwarder.(*transportForwarder)
+
+	if s.OnPodSelected != nil {
+		s.OnPodSelected(newPodInfo(pod))
+	}
+
+	if s.ShowEquivalentCommand || s.Verbose {
+		_, _ = fmt.Fprintf(s.ErrOut, "Equivalent command: %s\n", s.equivalentCommand(pod))
+	}
+
+	event := s.newEvent(EventStarting)
+	event.Pod = pod.Name
+	event.PodIP = pod.Status.PodIP
+	event.Node = pod.Spec.NodeName
+	event.MatchedPods = selection.Matched
+	event.ReadyPods = selection.Ready
+	event.SelectionReason = selection.Reason
+	startingText := fmt.Sprintf("Starting port-forward from %s to %s:%s on %s", s.LocalAddress, pod.Name, s.RemotePort, s.ContextName)
+	if pod.Status.PodIP != "" {
+		startingText = fmt.Sprintf("%s (pod IP %s on node %s)", startingText, pod.Status.PodIP, pod.Spec.NodeName)
+	}
+	if selection.Matched > 1 {
+		startingText = fmt.Sprintf("%s (selected from %d matching pods, %d ready: %s)", startingText, selection.Matched, selection.Ready, selection.Reason)
+	}
+	if err = s.writeEvent(event, startingText); err != nil {
+		return false, err
+	}
+
+	// readyCh is closed once port-forwarding is ready and, with GRPCHealthCheck, the gRPC service is serving.
+	readyCh := make(chan struct{})
+	go func() {
+		select {
+		case <-portForwardOptions.ReadyChannel:
+		case <-ctx.Done():
+			return
+		}
+		if s.GRPCHealthCheck {
+			if err := s.awaitGRPCHealth(ctx); err != nil {
+				return
+			}
+		}
+		close(readyCh)
+		event.Type = EventReady
+		_ = s.writeEvent(event, "")
+		s.readyOnce.Do(func() {
+			close(s.firstReady)
+			// A ReadyChannel already closed by an earlier use of the Settings is not closed again.
+			if s.ReadyChannel != nil && s.ReadyChannel != s.closedReady {
+				close(s.ReadyChannel)
+				s.closedReady = s.ReadyChannel
+			}
+		})
+	}()
+
+	if s.StreamLogs {
+		go s.streamLogs(ctx, pod)
+	}
+
+	if s.RotateOnNotReady {
+		go s.rotateOnNotReady(ctx, pod)
+	}
+
+	if s.FollowLatest {
+		go s.followLatest(ctx, pod)
+	}
+
+	if s.KeepAlive > 0 {
+		go s.keepAlive(readyCh, ctx.Done())
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- runPortForward(ctx, portForwardOptions, !s.draining())
+	}()
+	s.active.Store(true)
+	podInfo := newPodInfo(pod)
+	s.currentPod.Store(&podInfo)
+	defer func() {
+		s.active.Store(false)
+		s.ready.Store(false)
+		s.currentPod.Store(nil)
+	}()
+
+	var readyTimeout <-chan time.Time
+	if s.ReadyTimeout > 0 {
+		timer := time.NewTimer(s.ReadyTimeout)
+		defer timer.Stop()
+		readyTimeout = timer.C
+	}
+
+	var stopping <-chan struct{}
+	if s.StopTimeout > 0 {
+		stopping = ctx.Done()
+	}
+	var stopTimeout <-chan time.Time
+
+	var ready <-chan struct{} = readyCh
+	established := false
+	var readyAt time.Time
+	defer func() {
+		if established {
+			s.uptime, s.uptimePod = time.Since(readyAt), pod.Name
+		}
+	}()
+
+	for {
+		select {
+		case <-ready:
+			established, readyAt = true, time.Now()
+			s.ready.Store(true)
+			ready, readyTimeout = nil, nil
+		case <-readyTimeout:
+			// Wait for the local listener to be released, so that a retry can bind it again.
+			cancel()
+			s.awaitStopped(errCh, forwarder)
+			return false, fmt.Errorf("port-forward from %s to %s:%s on %s did not become ready within %s", s.LocalAddress, pod.Name, s.RemotePort, s.ContextName, s.ReadyTimeout)
+		case <-stopping:
+			timer := time.NewTimer(s.StopTimeout)
+			defer timer.Stop()
+			stopping, stopTimeout = nil, timer.C
+		case <-stopTimeout:
+			// The port-forwarder is abandoned, with its local listener closed, but its goroutine remains until its
+			// connection to the pod is released. Stopping was asked for, so it is not reported as an error.
+			forwarder.closeListeners()
+			_, _ = fmt.Fprintf(s.ErrOut, "Warning: port-forward from %s to %s:%s on %s did not stop within %s, so was abandoned\n", s.LocalAddress, pod.Name, s.RemotePort, s.ContextName, s.StopTimeout)
+			return established, ctx.Err()
+		case stopped := <-s.rebindChannel():
+			s.previousPod = pod.Name
+			cancel()
+			// Wait for the local listener to be released before it is bound again.
+			if !s.awaitStopped(errCh, forwarder) {
+				close(stopped)
+				return established, fmt.Errorf("port-forward from %s to %s:%s on %s did not stop for rebinding within %s", s.LocalAddress, pod.Name, s.RemotePort, s.ContextName, s.StopTimeout)
+			}
+			close(stopped)
+			return established, errRebind
+		case err = <-errCh:
+			select {
+			case <-ready:
+				established, readyAt = true, time.Now()
+			default:
+			}
+			if err != nil {
+				err = s.forwardError(pod.Name, err)
+				if established {
+					disconnected := s.newEvent(EventDisconnected)
+					disconnected.Pod = pod.Name
+					disconnected.Uptime = time.Since(readyAt)
+					disconnected.Err = err
+					_ = s.writeEvent(disconnected, "")
+				}
+				return established, err
+			}
+			return established, nil
+		}
+	}
+}
+
+// awaitStopped waits for the cancelled port-forwarder to end, so that its local listener is released, for at most
+// s.StopTimeout if given. If it does not end in time, its local listener is closed, and false is returned.
+func (s *Settings) awaitStopped(errCh <-chan error, forwarder *transportForwarder) bool {
+	var timeout <-chan time.Time
+	if s.StopTimeout > 0 {
+		timer := time.NewTimer(s.StopTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-errCh:
+		return true
+	case <-timeout:
+		forwarder.closeListeners()
+		return false
+	}
+}
+
+// Ping checks that the k8s cluster of the k8s context is reachable and that the credentials for it are accepted,
+// without selecting a pod or starting port-forwarding. As it configures the k8s clients of s, it must not be called
+// while port-forwarding with s is running, and returns an error if it is.
+func (s *Settings) Ping(ctx context.Context) error {
+	if s.running.Load() {
+		return &Error{Code: CodeInvalidSettings, Err: fmt.Errorf("Ping %w", errRunning)}
+	}
+	if err := s.prepare(); err != nil {
+		return err
+	}
+
+	if err := s.clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+		return &Error{Code: CodeClusterUnreachable, Err: fmt.Errorf("error reaching the k8s cluster for '%s' context: %w", s.ContextName, err)}
+	}
+
+	return nil
+}
+
+// prepare validates the settings and creates the k8s REST config and client set for the k8s context.
+func (s *Settings) prepare() error {
+	if err := s.Validate(); err != nil {
+		return &Error{Code: CodeInvalidSettings, Err: err}
+	}
+
+	if s.APIServer != "" {
+		s.verbosef("Using k8s API server %s without a kubeconfig", s.APIServer)
+		s.restConfig = &rest.Config{Host: s.APIServer}
+		s.namespace = metav1.NamespaceDefault
+	} else if err := s.loadKubeconfig(); err != nil {
+		return err
+	}
+	if s.Namespace != "" {
+		s.namespace = s.Namespace
+	}
+
+	if s.proxyURL != nil {
+		s.restConfig.Proxy = http.ProxyURL(s.proxyURL)
 	}
 
-	clientset, err := kubernetes.NewForConfig(restConfig)
+	if s.InsecureSkipTLSVerify {
+		_, _ = fmt.Fprintf(s.ErrOut, "Warning: the certificate of the k8s API server for '%s' context is not being verified\n", s.ContextName)
+		s.restConfig.Insecure = true
+		// Root certificates cannot be given with Insecure.
+		s.restConfig.CAFile = ""
+		s.restConfig.CAData = nil
+	}
+	if s.TLSServerName != "" {
+		s.restConfig.ServerName = s.TLSServerName
+	}
+	if len(s.ClientCertData) > 0 {
+		s.restConfig.CertData, s.restConfig.CertFile = s.ClientCertData, ""
+		s.restConfig.KeyData, s.restConfig.KeyFile = s.ClientKeyData, ""
+	}
+	if len(s.CAData) > 0 {
+		s.restConfig.CAData, s.restConfig.CAFile = s.CAData, ""
+	}
+	if s.BearerToken != "" || s.BearerTokenFile != "" {
+		s.restConfig.BearerToken, s.restConfig.BearerTokenFile = s.BearerToken, s.BearerTokenFile
+		// Other credentials of the kubeconfig cannot be used with a bearer token.
+		s.restConfig.Username, s.restConfig.Password = "", ""
+		s.restConfig.AuthProvider, s.restConfig.ExecProvider = nil, nil
+	}
+
+	s.restConfig.UserAgent = s.UserAgent
+
+	if len(s.ExtraHeaders) > 0 {
+		s.restConfig.Wrap(wrapHeaders(s.ExtraHeaders))
+	}
+
+	if s.Impersonate != "" {
+		s.restConfig.Impersonate = rest.ImpersonationConfig{
+			UserName: s.Impersonate,
+			Groups:   s.ImpersonateGroups,
+		}
+	}
+
+	if s.QPS > 0 {
+		s.restConfig.QPS = s.QPS
+	}
+	if s.Burst > 0 {
+		s.restConfig.Burst = s.Burst
+	}
+
+	// Long-lived requests use a client set without the request timeout, as it would end them.
+	var err error
+	s.streamClientset, err = kubernetes.NewForConfig(s.restConfig)
 	if err != nil {
 		return fmt.Errorf("error creating k8s client set: %w", err)
 	}
+	s.clientset = s.streamClientset
 
-	podClient := clientset.CoreV1()
-	labelSelector := fmt.Sprintf("app=%s", s.AppName)
-	missingErr := fmt.Errorf("no running pods found for app '%s' in '%s' context", s.AppName, s.ContextName)
+	if s.RequestTimeout > 0 {
+		s.restConfig.Timeout = s.RequestTimeout
+		s.clientset, err = kubernetes.NewForConfig(s.restConfig)
+		if err != nil {
+			return fmt.Errorf("error creating k8s client set: %w", err)
+		}
+	}
 
-	if s.VersionName != "" {
-		labelSelector = fmt.Sprintf("app=%s,version=%s", s.AppName, s.VersionName)
-		missingErr = fmt.Errorf("no running pods found for app '%s' version '%s' in '%s' context", s.AppName, s.VersionName, s.ContextName)
+	return nil
+}
+
+// loadKubeconfig sets the REST config and namespace of the k8s context from the kubeconfig.
+func (s *Settings) loadKubeconfig() error {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if s.KubeconfigPath != "" {
+		loadingRules.ExplicitPath = s.KubeconfigPath
 	}
 
-	pods, err := podClient.Pods(k8sCtx.Namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: labelSelector,
-		FieldSelector: "status.phase=Running",
-	})
+	apiConfig, err := loadingRules.Load()
 	if err != nil {
-		return fmt.Errorf("error listing pods: %w", err)
+		kubeconfigPaths := strings.Join(loadingRules.GetLoadingPrecedence(), string(filepath.ListSeparator))
+		return &Error{Code: CodeKubeconfigInvalid, Err: fmt.Errorf("error loading the k8s config from %s: %w", kubeconfigPaths, err)}
+	}
+
+	k8sCtx, ok := apiConfig.Contexts[s.ContextName]
+	if !ok {
+		return &Error{Code: CodeContextNotFound, Err: fmt.Errorf("unknown k8s context '%s'", s.ContextName)}
+	}
+	s.namespace = k8sCtx.Namespace
+	if s.namespace == "" {
+		// As with kubectl, a k8s context without a namespace uses the default namespace.
+		s.namespace = metav1.NamespaceDefault
+	}
+
+	s.verbosef("Using k8s context '%s' with cluster '%s'", s.ContextName, k8sCtx.Cluster)
+	if apiConfig.CurrentContext != s.ContextName {
+		s.verbosef("Note: k8s context '%s' is not the current context '%s'", s.ContextName, apiConfig.CurrentContext)
+	}
+
+	if s.AuthInfoOverride != "" {
+		if _, ok := apiConfig.AuthInfos[s.AuthInfoOverride]; !ok {
+			return &Error{Code: CodeKubeconfigInvalid, Err: fmt.Errorf("unknown kubeconfig user '%s'", s.AuthInfoOverride)}
+		}
+		s.verbosef("Using kubeconfig user '%s' instead of '%s'", s.AuthInfoOverride, k8sCtx.AuthInfo)
+	}
+	if s.ClusterOverride != "" {
+		if _, ok := apiConfig.Clusters[s.ClusterOverride]; !ok {
+			return &Error{Code: CodeKubeconfigInvalid, Err: fmt.Errorf("unknown kubeconfig cluster '%s'", s.ClusterOverride)}
+		}
+		s.verbosef("Using kubeconfig cluster '%s' instead of '%s'", s.ClusterOverride, k8sCtx.Cluster)
 	}
 
-	if len(pods.Items) == 0 {
-		return missingErr
+	clientConfig := clientcmd.NewDefaultClientConfig(*apiConfig, &clientcmd.ConfigOverrides{
+		CurrentContext: s.ContextName,
+		Context: clientcmdapi.Context{
+			AuthInfo: s.AuthInfoOverride,
+			Cluster:  s.ClusterOverride,
+		},
+	})
+
+	s.restConfig, err = clientConfig.ClientConfig()
+	if err != nil {
+		return &Error{Code: CodeKubeconfigInvalid, Err: fmt.Errorf("error creating the k8s client REST config: %w", err)}
 	}
+	return nil
+}
 
-	var podName string
-	for _, pod := range pods.Items {
-		// Just pick the first running pod matching the label selector
-		podName = pod.Name
-		break
+// portForwardOptions builds the port-forwarding options for forwarding to the given pod.
+func (s *Settings) portForwardOptions(pod *corev1.Pod) (*portforward.PortForwardOptions, error) {
+	forwarderOut := s.Out
+	if s.OutputFormat == OutputFormatJSON {
+		forwarderOut = io.Discard
 	}
 
 	portForwardOptions := portforward.NewDefaultPortForwardOptions(
 		genericiooptions.IOStreams{
-			In:     os.Stdin,
-			Out:    s.Out,
+			In:     s.In,
+			Out:    forwarderOut,
 			ErrOut: s.ErrOut,
 		},
 	)
 
+	restConfig := rest.CopyConfig(s.restConfig)
+	// The port-forwarding connection is long-lived, so must not be ended by RequestTimeout.
+	restConfig.Timeout = 0
 	restConfig.GroupVersion = &schema.GroupVersion{Group: "", Version: "v1"}
 	restConfig.APIPath = "/api"
 	restConfig.NegotiatedSerializer = serializer.WithoutConversionCodecFactory{CodecFactory: scheme.Codecs}
 
+	var err error
 	portForwardOptions.RESTClient, err = rest.RESTClientFor(restConfig)
 	if err != nil {
-		return fmt.Errorf("error configuring REST client: %w", err)
+		return nil, fmt.Errorf("error configuring REST client: %w", err)
 	}
 
-	portForwardOptions.PodClient = clientset.CoreV1()
-	portForwardOptions.Namespace = k8sCtx.Namespace
-	portForwardOptions.PodName = podName
-	portForwardOptions.Address = []string{s.localHost}
-	portForwardOptions.Ports = []string{fmt.Sprintf("%s:%s", s.localPort, s.RemotePort)}
+	portForwardOptions.PodClient = s.clientset.CoreV1()
+	// Use the namespace of the selected pod, which may differ from the k8s context namespace with AllNamespaces.
+	portForwardOptions.Namespace = pod.Namespace
+	portForwardOptions.PodName = pod.Name
+	// With DeferAccept or Trace, the port-forwarder listens on s.forwardAddress, and LocalAddress is relayed to it.
+	forwardHost, forwardPort, err := net.SplitHostPort(s.forwardAddress)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing forward address %s: %w", s.forwardAddress, err)
+	}
+	portForwardOptions.Address = []string{forwardHost}
+	portForwardOptions.Ports = []string{fmt.Sprintf("%s:%s", forwardPort, s.RemotePort)}
 	portForwardOptions.Config = restConfig
+	portForwardOptions.PortForwarder = &transportForwarder{transport: s.Transport, out: forwarderOut, errOut: s.ErrOut}
 
 	portForwardOptions.StopChannel = make(chan struct{}, 1)
 
-	if s.ReadyChannel != nil {
-		portForwardOptions.ReadyChannel = s.ReadyChannel
-	} else {
-		portForwardOptions.ReadyChannel = make(chan struct{})
-	}
+	// A new ready channel is needed for each forward, as the port-forwarder closes it. s.ReadyChannel is closed
+	// separately on the first forward becoming ready.
+	portForwardOptions.ReadyChannel = make(chan struct{})
 
 	if err = portForwardOptions.Validate(); err != nil {
-		return fmt.Errorf("error validating the port-forwarding options: %w", err)
-	}
-
-	if _, err = fmt.Fprintf(s.Out, "Starting port-forward from %s to %s:%s on %s\n", s.LocalAddress, podName, s.RemotePort, s.ContextName); err != nil {
-		return fmt.Errorf("error writing to output stream: %w", err)
-	}
-
-	if err = portForwardOptions.RunPortForwardContext(ctx); err != nil {
-		return fmt.Errorf("error port-forwarding from %s to %s:%s on %s: %w", s.LocalAddress, podName, s.RemotePort, s.ContextName, err)
+		return nil, fmt.Errorf("error validating the port-forwarding options: %w", err)
 	}
 
-	return nil
+	return portForwardOptions, nil
 }
@@ -0,0 +1,50 @@
+package k8sforward
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings *Settings
+		want     []string
+	}{
+		{
+			name:     "valid",
+			settings: &Settings{ContextName: "dev", AppName: "web", LocalAddress: "localhost:8080", RemotePort: "80"},
+		},
+		{
+			name:     "several problems",
+			settings: &Settings{AppName: "web api", LocalAddress: "localhost", RemotePort: "0"},
+			want:     []string{"k8s context name", "k8s app name 'web api'", "local address", "remote TCP port"},
+		},
+		{
+			name:     "conflicting settings",
+			settings: &Settings{ContextName: "dev", Namespace: "team", AllNamespaces: true, DeploymentName: "web", JobName: "migrate", LocalAddress: "localhost:8080", RemotePort: "80"},
+			want:     []string{"namespace and all namespaces", "Job", "all namespaces cannot be used with a Job name"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := test.settings.ValidateAll()
+			if len(errs) != len(test.want) {
+				t.Fatalf("ValidateAll returned %d errors %v, want %d", len(errs), errs, len(test.want))
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), test.want[i]) {
+					t.Errorf("error %d was '%v', want it to mention '%s'", i+1, err, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateReturnsFirstError(t *testing.T) {
+	s := &Settings{AppName: "web", LocalAddress: "localhost:8080"}
+	err := s.Validate()
+	if err == nil || !strings.Contains(err.Error(), "k8s context name") {
+		t.Errorf("Validate returned '%v', want the missing k8s context name", err)
+	}
+}
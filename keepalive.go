@@ -0,0 +1,34 @@
+package k8sforward
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// keepAlive opens and immediately closes a connection through the forward every s.KeepAlive once ready is closed,
+// so that traffic flows over an otherwise idle tunnel. It returns when done is closed.
+func (s *Settings) keepAlive(ready <-chan struct{}, done <-chan struct{}) {
+	select {
+	case <-ready:
+	case <-done:
+		return
+	}
+
+	ticker := time.NewTicker(s.KeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			conn, err := net.DialTimeout("tcp", s.forwardAddress, s.KeepAlive)
+			if err != nil {
+				_, _ = fmt.Fprintf(s.ErrOut, "keepalive connection to %s failed: %v\n", s.forwardAddress, err)
+				continue
+			}
+			_ = conn.Close()
+		case <-done:
+			return
+		}
+	}
+}
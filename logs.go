@@ -0,0 +1,46 @@
+package k8sforward
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// defaultContainerAnnotation is the pod annotation naming the container whose logs kubectl shows by default.
+const defaultContainerAnnotation = "kubectl.kubernetes.io/default-container"
+
+// streamLogs writes the logs of pod to s.LogOut, following them until ctx is done.
+// Errors are written to s.ErrOut, as they do not end port-forwarding.
+func (s *Settings) streamLogs(ctx context.Context, pod *corev1.Pod) {
+	logOptions := &corev1.PodLogOptions{
+		Container: logContainer(pod),
+		Follow:    true,
+	}
+
+	stream, err := s.streamClientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, logOptions).Stream(ctx)
+	if err != nil {
+		_, _ = fmt.Fprintf(s.ErrOut, "Error streaming logs of pod %s: %v\n", pod.Name, err)
+		return
+	}
+	defer func() {
+		_ = stream.Close()
+	}()
+
+	if _, err = io.Copy(s.LogOut, stream); err != nil && ctx.Err() == nil {
+		_, _ = fmt.Fprintf(s.ErrOut, "Error streaming logs of pod %s: %v\n", pod.Name, err)
+	}
+}
+
+// logContainer returns the container of pod whose logs are streamed: the default container named by its annotation,
+// or else its first container.
+func logContainer(pod *corev1.Pod) string {
+	if container := pod.Annotations[defaultContainerAnnotation]; container != "" {
+		return container
+	}
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Name
+	}
+	return ""
+}
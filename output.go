@@ -0,0 +1,134 @@
+package k8sforward
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// OutputFormatText writes lifecycle events as human-readable lines. This is the default.
+	OutputFormatText = "text"
+	// OutputFormatJSON writes lifecycle events as single-line JSON objects.
+	OutputFormatJSON = "json"
+)
+
+// EventType is the type of a lifecycle Event.
+type EventType string
+
+const (
+	// EventStarting is sent when port-forwarding to a selected pod is starting.
+	EventStarting EventType = "starting"
+	// EventReady is sent when port-forwarding is ready.
+	EventReady EventType = "ready"
+	// EventDisconnected is sent when port-forwarding that was ready ends with an error.
+	EventDisconnected EventType = "disconnected"
+	// EventReconnecting is sent when port-forwarding is about to be re-established after an error.
+	EventReconnecting EventType = "reconnecting"
+	// EventStopped is sent when Init returns.
+	EventStopped EventType = "stopped"
+	// EventError is sent when Init is about to return an error.
+	EventError EventType = "error"
+)
+
+// Event is a lifecycle event of port-forwarding, sent on Settings.Events and written to Settings.Out with OutputFormatJSON.
+type Event struct {
+	Type         EventType `json:"event"`
+	Context      string    `json:"context,omitempty"`
+	Pod          string    `json:"pod,omitempty"`
+	PodIP        string    `json:"podIP,omitempty"`
+	Node         string    `json:"node,omitempty"`
+	LocalAddress string    `json:"localAddress,omitempty"`
+	RemotePort   string    `json:"remotePort,omitempty"`
+	MatchedPods  int       `json:"matchedPods,omitempty"`
+	// ReadyPods is how many of the matching pods were ready, for EventStarting events.
+	ReadyPods int `json:"readyPods,omitempty"`
+	// SelectionReason is why the pod was selected among the matching pods, for EventStarting events.
+	SelectionReason string `json:"selectionReason,omitempty"`
+	// Uptime is how long port-forwarding had been ready, for EventDisconnected and EventStopped events.
+	Uptime time.Duration `json:"-"`
+	Err    error         `json:"-"`
+}
+
+// writeEvent sends the event on s.Events without blocking, and writes it to s.Out in the configured output format.
+// In text format, text is written instead of the event, and nothing is written if text is empty.
+func (s *Settings) writeEvent(event Event, text string) error {
+	if s.Events != nil {
+		select {
+		case s.Events <- event:
+		default:
+		}
+	}
+
+	if s.Out == nil {
+		// Validation failed before s.Out was defaulted.
+		return nil
+	}
+
+	if s.OutputFormat == OutputFormatJSON {
+		output := struct {
+			Event
+			UptimeSeconds float64 `json:"uptimeSeconds,omitempty"`
+			Error         string  `json:"error,omitempty"`
+		}{Event: event, UptimeSeconds: event.Uptime.Seconds()}
+		if event.Err != nil {
+			output.Error = event.Err.Error()
+		}
+		line, err := json.Marshal(output)
+		if err != nil {
+			return fmt.Errorf("error encoding %s event: %w", event.Type, err)
+		}
+		if _, err = fmt.Fprintf(s.Out, "%s\n", line); err != nil {
+			return fmt.Errorf("error writing to output stream: %w", err)
+		}
+		return nil
+	}
+
+	if text == "" {
+		return nil
+	}
+	if _, err := fmt.Fprintln(s.Out, text); err != nil {
+		return fmt.Errorf("error writing to output stream: %w", err)
+	}
+	return nil
+}
+
+// newEvent returns an event of the given type for the settings.
+func (s *Settings) newEvent(eventType EventType) Event {
+	return Event{
+		Type:         eventType,
+		Context:      s.ContextName,
+		LocalAddress: s.LocalAddress,
+		RemotePort:   s.RemotePort,
+	}
+}
+
+// verbosef writes a diagnostic message to s.ErrOut if s.Verbose is true.
+func (s *Settings) verbosef(format string, args ...any) {
+	if s.Verbose {
+		_, _ = fmt.Fprintf(s.ErrOut, format+"\n", args...)
+	}
+}
+
+// equivalentCommand returns the kubectl command equivalent to port-forwarding to pod.
+func (s *Settings) equivalentCommand(pod *corev1.Pod) string {
+	args := []string{"kubectl"}
+	if s.APIServer != "" {
+		args = append(args, "--server", s.APIServer)
+	} else {
+		if s.KubeconfigPath != "" {
+			args = append(args, "--kubeconfig", s.KubeconfigPath)
+		}
+		args = append(args, "--context", s.ContextName)
+	}
+	args = append(args, "-n", pod.Namespace, "port-forward")
+	// kubectl listens on localhost by default.
+	if s.localHost != "localhost" {
+		args = append(args, "--address", s.localHost)
+	}
+	args = append(args, "pod/"+pod.Name, fmt.Sprintf("%s:%s", s.localPort, s.RemotePort))
+	return strings.Join(args, " ")
+}
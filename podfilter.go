@@ -0,0 +1,33 @@
+package k8sforward
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodFilter chooses the pod to forward to from the running pods matching the settings, which are sorted by name.
+// It is never called with an empty slice.
+type PodFilter func(pods []corev1.Pod) (corev1.Pod, error)
+
+// FirstPod is the default PodFilter. It chooses the pod with the lowest name.
+func FirstPod(pods []corev1.Pod) (corev1.Pod, error) {
+	if len(pods) == 0 {
+		return corev1.Pod{}, fmt.Errorf("no pods to choose from")
+	}
+	return pods[0], nil
+}
+
+// NewestPod is a PodFilter which chooses the most recently created pod.
+func NewestPod(pods []corev1.Pod) (corev1.Pod, error) {
+	if len(pods) == 0 {
+		return corev1.Pod{}, fmt.Errorf("no pods to choose from")
+	}
+	newest := pods[0]
+	for _, pod := range pods[1:] {
+		if newest.CreationTimestamp.Before(&pod.CreationTimestamp) {
+			newest = pod
+		}
+	}
+	return newest, nil
+}
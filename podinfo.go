@@ -0,0 +1,36 @@
+package k8sforward
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PodInfo describes a pod.
+type PodInfo struct {
+	Name      string
+	Namespace string
+	Node      string
+	// Phase is the phase of the pod, such as "Running".
+	Phase string
+	// Ready is whether the pod was ready.
+	Ready  bool
+	Labels map[string]string
+	// Age is how long the pod had existed for when the PodInfo was made.
+	Age time.Duration
+	// Ports are the ports declared by the containers of the pod.
+	Ports []PortInfo
+}
+
+func newPodInfo(pod *corev1.Pod) PodInfo {
+	return PodInfo{
+		Name:      pod.Name,
+		Namespace: pod.Namespace,
+		Node:      pod.Spec.NodeName,
+		Phase:     string(pod.Status.Phase),
+		Ready:     isPodReady(pod),
+		Labels:    pod.Labels,
+		Age:       time.Since(pod.CreationTimestamp.Time),
+		Ports:     podPorts(pod),
+	}
+}
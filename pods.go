@@ -0,0 +1,504 @@
+package k8sforward
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// Selection summarises how a pod was selected for port-forwarding.
+type Selection struct {
+	// Matched is the number of running pods matching the settings.
+	Matched int
+	// Ready is the number of the matching pods which were ready.
+	Ready int
+	// Reason is why the selected pod was chosen among the matching pods.
+	Reason string
+}
+
+// selectPod returns a running pod matching the settings using podClient, and a summary of its selection.
+// It depends on the k8s cluster only through podClient, so that it can be used with a fake client, except with
+// ReadyEndpointsOnly.
+func (s *Settings) selectPod(ctx context.Context, podClient corev1client.CoreV1Interface) (*corev1.Pod, Selection, error) {
+	if s.StatefulSetName != "" {
+		pod, err := s.statefulSetPod(ctx, podClient)
+		if err != nil {
+			return nil, Selection{}, err
+		}
+		return pod, newSelection([]corev1.Pod{*pod}, fmt.Sprintf("ordinal %d of StatefulSet", s.Ordinal)), nil
+	}
+
+	if s.podName != "" {
+		pod, err := s.namedPod(ctx, podClient)
+		if err != nil {
+			return nil, Selection{}, err
+		}
+		return pod, newSelection([]corev1.Pod{*pod}, "named by target"), nil
+	}
+
+	labelSelector, description := s.podLabelSelector()
+	missingErr := fmt.Errorf("%w for %s in '%s' context", errNoPods, description, s.ContextName)
+
+	var items []corev1.Pod
+	var err error
+	if len(s.Pods) > 0 {
+		items, err = s.providedPods(labelSelector)
+	} else {
+		items, err = s.listPods(ctx, podClient, labelSelector)
+	}
+	if err != nil {
+		return nil, Selection{}, err
+	}
+
+	if len(items) == 0 {
+		return nil, Selection{}, s.explainRejections(ctx, podClient, labelSelector, missingErr)
+	}
+
+	if s.ReadyEndpointsOnly {
+		matched := len(items)
+		if items, err = s.filterReadyEndpoints(ctx, items); err != nil {
+			return nil, Selection{}, err
+		}
+		if len(items) == 0 {
+			return nil, Selection{}, fmt.Errorf("%w; none of the %d matching pods is a ready endpoint of Service '%s'", missingErr, matched, s.serviceName)
+		}
+	}
+
+	if s.RequireSingleApp {
+		if err = requireSingleApp(items, s.AppLabelKey); err != nil {
+			return nil, Selection{}, err
+		}
+	}
+
+	// Sort by name so that the pod selected is deterministic, as the API server does not guarantee the order.
+	slices.SortFunc(items, func(a, b corev1.Pod) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	var reasons []string
+	if s.ReadyEndpointsOnly {
+		reasons = append(reasons, "among ready Service endpoints")
+	}
+
+	// Prefer a pod other than any pod forwarded to before a Rebind.
+	candidates := items
+	if len(items) > 1 {
+		candidates = slices.DeleteFunc(slices.Clone(items), func(pod corev1.Pod) bool {
+			return pod.Name == s.previousPod
+		})
+	}
+	if len(candidates) < len(items) {
+		reasons = append(reasons, fmt.Sprintf("excluding previous pod '%s'", s.previousPod))
+	}
+
+	if len(candidates) > 1 && (s.RotateOnNotReady || s.WaitForReadyPod > 0) {
+		// Having waited for a ready pod, forward to it, and rotating to a pod which is not ready would only rotate again.
+		if ready := readyPods(candidates); len(ready) > 0 && len(ready) < len(candidates) {
+			candidates = ready
+			reasons = append(reasons, "preferring ready pods")
+		}
+	}
+
+	if len(candidates) > 1 && s.FollowLatest {
+		candidates = latestRolloutPods(candidates)
+		reasons = append(reasons, "from the latest rollout")
+	}
+
+	if len(candidates) > 1 && s.PreferZone != "" {
+		candidates = s.preferZone(ctx, podClient, candidates)
+		reasons = append(reasons, fmt.Sprintf("preferring zone '%s'", s.PreferZone))
+	}
+
+	if len(candidates) > 1 && s.OnMultiplePods != nil {
+		pod, err := s.choosePod(candidates)
+		reasons = append([]string{"chosen by OnMultiplePods"}, reasons...)
+		return pod, newSelection(items, strings.Join(reasons, ", ")), err
+	}
+
+	podFilter := s.PodFilter
+	switch {
+	case len(items) == 1:
+		reasons = append(reasons, "only matching pod")
+	case podFilter == nil:
+		reasons = append([]string{"first by name"}, reasons...)
+	default:
+		reasons = append([]string{"chosen by PodFilter"}, reasons...)
+	}
+	if podFilter == nil {
+		podFilter = FirstPod
+	}
+
+	pod, err := podFilter(candidates)
+	if err != nil {
+		return nil, Selection{}, fmt.Errorf("error filtering matching pods: %w", err)
+	}
+	return &pod, newSelection(items, strings.Join(reasons, ", ")), nil
+}
+
+// readyPods returns the pods of pods which are ready and not terminating.
+func readyPods(pods []corev1.Pod) []corev1.Pod {
+	var ready []corev1.Pod
+	for i := range pods {
+		if isPodReady(&pods[i]) && pods[i].DeletionTimestamp == nil {
+			ready = append(ready, pods[i])
+		}
+	}
+	return ready
+}
+
+// newSelection returns the selection from the matching pods for reason.
+func newSelection(pods []corev1.Pod, reason string) Selection {
+	selection := Selection{Matched: len(pods), Reason: reason}
+	for i := range pods {
+		if isPodReady(&pods[i]) {
+			selection.Ready++
+		}
+	}
+	return selection
+}
+
+// preferZone returns the ready pods of pods whose nodes are in the topology zone s.PreferZone, or pods if there are none.
+// Nodes which cannot be got, such as without permission to get nodes, are treated as in another zone.
+func (s *Settings) preferZone(ctx context.Context, podClient corev1client.CoreV1Interface, pods []corev1.Pod) []corev1.Pod {
+	zones := map[string]string{}
+	var preferred []corev1.Pod
+	for _, pod := range pods {
+		if !isPodReady(&pod) {
+			continue
+		}
+		zone, ok := zones[pod.Spec.NodeName]
+		if !ok {
+			node, err := podClient.Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+			if err != nil {
+				s.verbosef("Could not get the zone of node '%s': %v", pod.Spec.NodeName, err)
+			} else {
+				zone = node.Labels[corev1.LabelTopologyZone]
+			}
+			zones[pod.Spec.NodeName] = zone
+		}
+		if zone == s.PreferZone {
+			preferred = append(preferred, pod)
+		}
+	}
+
+	if len(preferred) == 0 {
+		s.verbosef("No ready matching pods are in zone '%s'", s.PreferZone)
+		return pods
+	}
+	return preferred
+}
+
+// choosePod returns the pod chosen by s.OnMultiplePods from pods.
+func (s *Settings) choosePod(pods []corev1.Pod) (*corev1.Pod, error) {
+	infos := make([]PodInfo, len(pods))
+	for i := range pods {
+		infos[i] = newPodInfo(&pods[i])
+	}
+
+	chosen, err := s.OnMultiplePods(infos)
+	if err != nil {
+		return nil, fmt.Errorf("error choosing among matching pods: %w", err)
+	}
+
+	for i := range pods {
+		if pods[i].Name == chosen {
+			return &pods[i], nil
+		}
+	}
+	return nil, fmt.Errorf("chosen pod '%s' is not one of the matching pods", chosen)
+}
+
+// listPods lists the running pods matching labelSelector and the settings.
+// With ListLimit, pods are listed in pages of that size, stopping at the first page with any matching pods.
+// Listing is retried with a short backoff on transient API server errors.
+func (s *Settings) listPods(ctx context.Context, podClient corev1client.CoreV1Interface, labelSelector string) ([]corev1.Pod, error) {
+	namespace := s.namespace
+	if s.AllNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+
+	listOptions := metav1.ListOptions{
+		LabelSelector: labelSelector,
+		FieldSelector: s.podFieldSelector(),
+		Limit:         s.ListLimit,
+	}
+
+	for {
+		var pods *corev1.PodList
+		err := retry.OnError(retry.DefaultBackoff, isTransientError, func() error {
+			var err error
+			pods, err = podClient.Pods(namespace).List(ctx, listOptions)
+			return err
+		})
+		if apierrors.IsForbidden(err) {
+			return nil, fmt.Errorf("not permitted to list pods on %s, as the 'list' permission on 'pods' is required: %w", s.ContextName, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error listing pods: %w", err)
+		}
+
+		items, err := s.filterPods(pods.Items)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(items) > 0 || pods.Continue == "" {
+			return items, nil
+		}
+		listOptions.Continue = pods.Continue
+	}
+}
+
+// filterPods returns the pods matching the settings which cannot be selected for when listing pods.
+func (s *Settings) filterPods(pods []corev1.Pod) ([]corev1.Pod, error) {
+	if s.AppNamePrefix && s.AppName != "" && s.Selector == nil {
+		var err error
+		if pods, err = filterAppNamePrefix(pods, s.AppLabelKey, s.AppName); err != nil {
+			return nil, err
+		}
+	}
+	if len(s.AnnotationSelector) > 0 && s.Selector == nil {
+		pods = filterAnnotations(pods, s.AnnotationSelector)
+	}
+	if s.MinPodAge > 0 {
+		pods = filterMinPodAge(pods, s.MinPodAge, time.Now())
+	}
+	return pods, nil
+}
+
+// filterMinPodAge returns the pods which have been ready, or else have existed, for at least minAge at now.
+func filterMinPodAge(pods []corev1.Pod, minAge time.Duration, now time.Time) []corev1.Pod {
+	var matched []corev1.Pod
+	for _, pod := range pods {
+		since := pod.CreationTimestamp.Time
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				since = condition.LastTransitionTime.Time
+			}
+		}
+		if now.Sub(since) >= minAge {
+			matched = append(matched, pod)
+		}
+	}
+	return matched
+}
+
+// providedPods returns the running pods of s.Pods matching labelSelector and the settings, as listPods would.
+// Field selectors other than NodeName cannot be applied to them.
+func (s *Settings) providedPods(labelSelector string) ([]corev1.Pod, error) {
+	selector, err := labels.Parse(labelSelector)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing label selector '%s': %w", labelSelector, err)
+	}
+
+	var matched []corev1.Pod
+	for _, pod := range s.matchingProvidedPods(selector) {
+		if pod.Status.Phase == corev1.PodRunning || s.IncludeNonRunning {
+			matched = append(matched, pod)
+		}
+	}
+	return s.filterPods(matched)
+}
+
+// matchingProvidedPods returns the pods of s.Pods in any phase matching selector, the namespace and NodeName.
+func (s *Settings) matchingProvidedPods(selector labels.Selector) []corev1.Pod {
+	var matched []corev1.Pod
+	for _, pod := range s.Pods {
+		if !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if (s.NodeName != "" && s.Selector == nil && pod.Spec.NodeName != s.NodeName) || (!s.AllNamespaces && pod.Namespace != s.namespace) {
+			continue
+		}
+		matched = append(matched, pod)
+	}
+	return matched
+}
+
+// podFieldSelector returns the field selector for listing running pods, or pods in any phase with IncludeNonRunning,
+// matching the settings.
+func (s *Settings) podFieldSelector() string {
+	return s.fieldSelector(!s.IncludeNonRunning)
+}
+
+// fieldSelector returns the field selector for listing pods matching the settings, restricted to running pods if runningOnly.
+func (s *Settings) fieldSelector(runningOnly bool) string {
+	var fieldSelectors []string
+	if runningOnly {
+		fieldSelectors = append(fieldSelectors, "status.phase=Running")
+	}
+	if s.Selector != nil {
+		fieldSelectors = append(fieldSelectors, s.Selector.FieldSelector)
+	} else {
+		fieldSelectors = append(fieldSelectors, s.FieldSelector)
+		if s.NodeName != "" {
+			fieldSelectors = append(fieldSelectors, fmt.Sprintf("spec.nodeName=%s", s.NodeName))
+		}
+	}
+	return strings.Trim(strings.Join(fieldSelectors, ","), ",")
+}
+
+// isTransientError reports whether err is a k8s API server error which may succeed on retry.
+func isTransientError(err error) bool {
+	return apierrors.IsServerTimeout(err) || apierrors.IsInternalError(err) || apierrors.IsTooManyRequests(err)
+}
+
+// podLabelSelector returns the label selector for listing pods, and a description of the pods selected for error messages.
+func (s *Settings) podLabelSelector() (string, string) {
+	if s.Selector != nil {
+		description := fmt.Sprintf("label selector '%s'", s.Selector.LabelSelector)
+		if s.Selector.FieldSelector != "" {
+			description = fmt.Sprintf("%s field selector '%s'", description, s.Selector.FieldSelector)
+		}
+		return s.Selector.LabelSelector, description
+	}
+
+	var requirements, descriptions []string
+
+	switch {
+	case s.JobName != "":
+		requirements = append(requirements, s.workloadSelector)
+		descriptions = append(descriptions, fmt.Sprintf("Job '%s'", s.JobName))
+	case s.DeploymentName != "":
+		requirements = append(requirements, s.workloadSelector)
+		descriptions = append(descriptions, fmt.Sprintf("Deployment '%s'", s.DeploymentName))
+	case s.serviceName != "":
+		requirements = append(requirements, s.workloadSelector)
+		descriptions = append(descriptions, fmt.Sprintf("Service '%s'", s.serviceName))
+	case s.AppName == "":
+	case s.AppNamePrefix:
+		// Label selectors cannot match prefixes, so select pods with any app label and filter them after listing.
+		requirements = append(requirements, s.AppLabelKey)
+		descriptions = append(descriptions, fmt.Sprintf("app prefix '%s'", s.AppName))
+	default:
+		requirements = append(requirements, fmt.Sprintf("%s=%s", s.AppLabelKey, s.AppName))
+		descriptions = append(descriptions, fmt.Sprintf("app '%s'", s.AppName))
+	}
+
+	if s.VersionName != "" {
+		if len(s.versionNames) > 1 {
+			requirements = append(requirements, fmt.Sprintf("%s in (%s)", s.VersionLabelKey, strings.Join(s.versionNames, ",")))
+		} else {
+			requirements = append(requirements, fmt.Sprintf("%s=%s", s.VersionLabelKey, s.VersionName))
+		}
+		descriptions = append(descriptions, fmt.Sprintf("version '%s'", s.VersionName))
+	}
+
+	if s.NodeName != "" {
+		descriptions = append(descriptions, fmt.Sprintf("on node '%s'", s.NodeName))
+	}
+
+	if s.MinPodAge > 0 {
+		descriptions = append(descriptions, fmt.Sprintf("older than %s", s.MinPodAge))
+	}
+
+	if len(s.AnnotationSelector) > 0 {
+		descriptions = append(descriptions, fmt.Sprintf("annotations '%s'", labels.Set(s.AnnotationSelector)))
+	}
+
+	return strings.Join(requirements, ","), strings.Join(descriptions, " ")
+}
+
+// filterAnnotations returns the pods with all the given annotations.
+func filterAnnotations(pods []corev1.Pod, annotations map[string]string) []corev1.Pod {
+	var matched []corev1.Pod
+	for _, pod := range pods {
+		if matchesAnnotations(pod.Annotations, annotations) {
+			matched = append(matched, pod)
+		}
+	}
+	return matched
+}
+
+func matchesAnnotations(podAnnotations, annotations map[string]string) bool {
+	for key, value := range annotations {
+		if podValue, ok := podAnnotations[key]; !ok || podValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// filterAppNamePrefix returns the pods with an app label, with the key appLabelKey, starting with prefix.
+// It returns an error listing the matching apps if more than one app matches.
+func filterAppNamePrefix(pods []corev1.Pod, appLabelKey, prefix string) ([]corev1.Pod, error) {
+	var matched []corev1.Pod
+	apps := map[string]bool{}
+	for _, pod := range pods {
+		if app := pod.Labels[appLabelKey]; strings.HasPrefix(app, prefix) {
+			matched = append(matched, pod)
+			apps[app] = true
+		}
+	}
+
+	if len(apps) > 1 {
+		names := slices.Sorted(maps.Keys(apps))
+		return nil, fmt.Errorf("app prefix '%s' is ambiguous, matching apps: %s", prefix, strings.Join(names, ", "))
+	}
+
+	return matched, nil
+}
+
+// requireSingleApp returns an error listing the apps of pods, by the label with the key appLabelKey, if there is more than one.
+func requireSingleApp(pods []corev1.Pod, appLabelKey string) error {
+	apps := map[string]bool{}
+	for _, pod := range pods {
+		app, ok := pod.Labels[appLabelKey]
+		if !ok {
+			app = "(none)"
+		}
+		apps[app] = true
+	}
+
+	if len(apps) > 1 {
+		names := slices.Sorted(maps.Keys(apps))
+		return fmt.Errorf("matching pods are of more than one app: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// statefulSetPod returns the pod for s.Ordinal of s.StatefulSetName, which must be running.
+func (s *Settings) statefulSetPod(ctx context.Context, podClient corev1client.CoreV1Interface) (*corev1.Pod, error) {
+	podName := fmt.Sprintf("%s-%d", s.StatefulSetName, s.Ordinal)
+
+	pod, err := podClient.Pods(s.namespace).Get(ctx, podName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w for pod '%s' of StatefulSet '%s' in '%s' context", errNoPods, podName, s.StatefulSetName, s.ContextName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting pod '%s': %w", podName, err)
+	}
+
+	if pod.Status.Phase != corev1.PodRunning && !s.IncludeNonRunning {
+		return nil, fmt.Errorf("pod '%s' of StatefulSet '%s' in '%s' context is not running but %s", podName, s.StatefulSetName, s.ContextName, pod.Status.Phase)
+	}
+
+	return pod, nil
+}
+
+// namedPod returns the pod of s.Target, which must be running unless IncludeNonRunning.
+func (s *Settings) namedPod(ctx context.Context, podClient corev1client.CoreV1Interface) (*corev1.Pod, error) {
+	pod, err := podClient.Pods(s.namespace).Get(ctx, s.podName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("%w for pod '%s' in '%s' context", errNoPods, s.podName, s.ContextName)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting pod '%s': %w", s.podName, err)
+	}
+
+	if pod.Status.Phase != corev1.PodRunning && !s.IncludeNonRunning {
+		return nil, fmt.Errorf("pod '%s' in '%s' context is not running but %s", s.podName, s.ContextName, pod.Status.Phase)
+	}
+
+	return pod, nil
+}
@@ -0,0 +1,277 @@
+package k8sforward
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// testPod returns a running, ready pod of app in the default namespace, created at created.
+func testPod(name, app string, created time.Time) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         "default",
+			Labels:            map[string]string{"app": app},
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{
+				Type:               corev1.PodReady,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(created),
+			}},
+		},
+	}
+}
+
+// testSettings returns Settings selecting pods of app in the default namespace, as prepared by Init.
+func testSettings(app string) *Settings {
+	return &Settings{
+		ContextName: "test",
+		AppName:     app,
+		AppLabelKey: defaultAppLabelKey,
+		namespace:   "default",
+	}
+}
+
+func TestSelectPodLowestName(t *testing.T) {
+	now := time.Now()
+	clientset := fake.NewClientset(
+		testPod("web-c", "web", now),
+		testPod("web-a", "web", now),
+		testPod("web-b", "web", now),
+		testPod("api-a", "api", now),
+	)
+
+	pod, selection, err := testSettings("web").selectPod(context.Background(), clientset.CoreV1())
+	if err != nil {
+		t.Fatalf("selectPod returned error: %v", err)
+	}
+	if pod.Name != "web-a" {
+		t.Errorf("selected pod %s, want web-a", pod.Name)
+	}
+	if selection.Matched != 3 || selection.Ready != 3 {
+		t.Errorf("selection matched %d with %d ready, want 3 with 3 ready", selection.Matched, selection.Ready)
+	}
+	if !strings.HasPrefix(selection.Reason, "first by name") {
+		t.Errorf("selection reason '%s', want it to start with 'first by name'", selection.Reason)
+	}
+}
+
+func TestSelectPodNoPods(t *testing.T) {
+	clientset := fake.NewClientset(testPod("api-a", "api", time.Now()))
+
+	_, _, err := testSettings("web").selectPod(context.Background(), clientset.CoreV1())
+	if !errors.Is(err, errNoPods) {
+		t.Fatalf("selectPod returned error %v, want errNoPods", err)
+	}
+	if ErrorCode(withCode(err)) != CodeNoPods {
+		t.Errorf("error code %s, want %s", ErrorCode(withCode(err)), CodeNoPods)
+	}
+	if strings.Contains(err.Error(), "rejected pods") {
+		t.Errorf("error '%v' explains rejections, but no pods were rejected", err)
+	}
+}
+
+func TestSelectPodRejectionExplained(t *testing.T) {
+	clientset := fake.NewClientset(testPod("web-a", "web", time.Now()))
+	s := testSettings("web")
+	s.MinPodAge = time.Hour
+
+	_, _, err := s.selectPod(context.Background(), clientset.CoreV1())
+	if !errors.Is(err, errNoPods) {
+		t.Fatalf("selectPod returned error %v, want errNoPods", err)
+	}
+	if want := "rejected pods: web-a: younger than 1h0m0s"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error '%v' does not contain '%s'", err, want)
+	}
+}
+
+func TestSelectPodProvidedPodsRejectionExplained(t *testing.T) {
+	pending := testPod("web-b", "web", time.Now())
+	pending.Status.Phase = corev1.PodPending
+	otherApp := testPod("api-x", "api", time.Now())
+	otherApp.Status.Phase = corev1.PodPending
+	otherNamespace := testPod("web-c", "web", time.Now())
+	otherNamespace.Namespace = "other"
+	otherNamespace.Status.Phase = corev1.PodPending
+
+	s := testSettings("web")
+	s.Pods = []corev1.Pod{*pending, *otherApp, *otherNamespace}
+
+	_, _, err := s.selectPod(context.Background(), fake.NewClientset().CoreV1())
+	if !errors.Is(err, errNoPods) {
+		t.Fatalf("selectPod returned error %v, want errNoPods", err)
+	}
+	if want := "rejected pods: web-b: Pending"; !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("error '%v' does not end with '%s'", err, want)
+	}
+}
+
+func TestSelectPodStrategies(t *testing.T) {
+	now := time.Now()
+	notReady := testPod("web-a", "web", now)
+	notReady.Status.Conditions = nil
+	terminating := testPod("web-a", "web", now)
+	terminating.DeletionTimestamp = &metav1.Time{Time: now}
+	terminating.Finalizers = []string{"example.com/hold"}
+
+	tests := []struct {
+		name       string
+		pods       []*corev1.Pod
+		configure  func(s *Settings)
+		want       string
+		wantReason string
+		wantErr    bool
+	}{
+		{
+			name: "PodFilter NewestPod",
+			pods: []*corev1.Pod{testPod("web-a", "web", now), testPod("web-b", "web", now.Add(-time.Hour))},
+			configure: func(s *Settings) {
+				s.PodFilter = NewestPod
+			},
+			want:       "web-a",
+			wantReason: "chosen by PodFilter",
+		},
+		{
+			name: "PodFilter error",
+			pods: []*corev1.Pod{testPod("web-a", "web", now), testPod("web-b", "web", now)},
+			configure: func(s *Settings) {
+				s.PodFilter = func([]corev1.Pod) (corev1.Pod, error) {
+					return corev1.Pod{}, errors.New("no suitable pod")
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "OnMultiplePods",
+			pods: []*corev1.Pod{testPod("web-a", "web", now), testPod("web-b", "web", now)},
+			configure: func(s *Settings) {
+				s.OnMultiplePods = func(pods []PodInfo) (string, error) {
+					return pods[len(pods)-1].Name, nil
+				}
+			},
+			want:       "web-b",
+			wantReason: "chosen by OnMultiplePods",
+		},
+		{
+			name: "OnMultiplePods choosing an unknown pod",
+			pods: []*corev1.Pod{testPod("web-a", "web", now), testPod("web-b", "web", now)},
+			configure: func(s *Settings) {
+				s.OnMultiplePods = func([]PodInfo) (string, error) {
+					return "web-z", nil
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "ready pod preferred",
+			pods: []*corev1.Pod{notReady, testPod("web-b", "web", now)},
+			configure: func(s *Settings) {
+				s.RotateOnNotReady = true
+			},
+			want:       "web-b",
+			wantReason: "preferring ready pods",
+		},
+		{
+			name: "terminating pod not preferred",
+			pods: []*corev1.Pod{terminating, testPod("web-b", "web", now)},
+			configure: func(s *Settings) {
+				s.WaitForReadyPod = time.Minute
+			},
+			want:       "web-b",
+			wantReason: "preferring ready pods",
+		},
+		{
+			name:       "pod not ready without rotation or waiting",
+			pods:       []*corev1.Pod{notReady, testPod("web-b", "web", now)},
+			want:       "web-a",
+			wantReason: "first by name",
+		},
+		{
+			name: "previous pod excluded",
+			pods: []*corev1.Pod{testPod("web-a", "web", now), testPod("web-b", "web", now)},
+			configure: func(s *Settings) {
+				s.previousPod = "web-a"
+			},
+			want:       "web-b",
+			wantReason: "excluding previous pod 'web-a'",
+		},
+		{
+			name: "previous pod kept as the only pod",
+			pods: []*corev1.Pod{testPod("web-a", "web", now)},
+			configure: func(s *Settings) {
+				s.previousPod = "web-a"
+			},
+			want:       "web-a",
+			wantReason: "only matching pod",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clientset := fake.NewClientset()
+			for _, pod := range test.pods {
+				if err := clientset.Tracker().Add(pod); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s := testSettings("web")
+			if test.configure != nil {
+				test.configure(s)
+			}
+
+			pod, selection, err := s.selectPod(context.Background(), clientset.CoreV1())
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("selectPod selected %s, want an error", pod.Name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectPod returned error: %v", err)
+			}
+			if pod.Name != test.want {
+				t.Errorf("selected pod %s, want %s", pod.Name, test.want)
+			}
+			if !strings.Contains(selection.Reason, test.wantReason) {
+				t.Errorf("selection reason '%s' does not contain '%s'", selection.Reason, test.wantReason)
+			}
+		})
+	}
+}
+
+func TestFilterMinPodAge(t *testing.T) {
+	now := time.Now()
+	notReady := testPod("not-ready", "web", now.Add(-2*time.Hour))
+	notReady.Status.Conditions = nil
+	recentlyReady := testPod("recently-ready", "web", now.Add(-2*time.Hour))
+	recentlyReady.Status.Conditions[0].LastTransitionTime = metav1.NewTime(now.Add(-time.Minute))
+
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want bool
+	}{
+		{name: "ready long enough", pod: testPod("old", "web", now.Add(-2*time.Hour)), want: true},
+		{name: "ready too recently", pod: testPod("young", "web", now.Add(-time.Minute)), want: false},
+		{name: "created long enough ago but ready too recently", pod: recentlyReady, want: false},
+		{name: "not ready but created long enough ago", pod: notReady, want: true},
+		{name: "exactly the minimum age", pod: testPod("exact", "web", now.Add(-time.Hour)), want: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matched := filterMinPodAge([]corev1.Pod{*test.pod}, time.Hour, now)
+			if got := len(matched) == 1; got != test.want {
+				t.Errorf("filterMinPodAge kept pod: %t, want %t", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package k8sforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// checkRemotePort returns an error with StrictPort if s.RemotePort is not declared by any container of pod.
+// Otherwise, a warning is written to s.ErrOut if the pod declares ports but not s.RemotePort.
+// For pods using the host network, s.RemotePort is checked against host ports instead.
+func (s *Settings) checkRemotePort(pod *corev1.Pod) error {
+	remotePort, err := strconv.Atoi(s.RemotePort)
+	if err != nil {
+		return fmt.Errorf("remote TCP port must be an integer but was '%s'", s.RemotePort)
+	}
+
+	if pod.Spec.HostNetwork {
+		return s.checkHostPort(pod, remotePort)
+	}
+
+	declaredPorts := 0
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if int(port.ContainerPort) == remotePort {
+				return nil
+			}
+			declaredPorts++
+		}
+	}
+
+	if s.StrictPort {
+		return fmt.Errorf("remote port %s is not declared by any container of pod '%s'", s.RemotePort, pod.Name)
+	}
+
+	if declaredPorts > 0 {
+		_, _ = fmt.Fprintf(s.ErrOut, "Warning: remote port %s is not declared by any container of pod '%s'\n", s.RemotePort, pod.Name)
+	}
+
+	return nil
+}
+
+// checkHostPort checks remotePort for pod, which uses the network of its node, so that port-forwarding reaches the port
+// on the node rather than one of the pod alone. It returns an error with StrictPort if remotePort is not declared as a
+// host port by any container of pod, and otherwise writes a warning to s.ErrOut.
+func (s *Settings) checkHostPort(pod *corev1.Pod, remotePort int) error {
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if int(port.HostPort) == remotePort {
+				return nil
+			}
+		}
+	}
+
+	if s.StrictPort {
+		return fmt.Errorf("remote port %s is not declared as a host port by any container of pod '%s', which uses the host network", s.RemotePort, pod.Name)
+	}
+
+	_, _ = fmt.Fprintf(s.ErrOut, "Warning: pod '%s' uses the host network of node '%s', and remote port %s is not declared as a host port "+
+		"by any of its containers, so port-forwarding reaches whatever listens on that port of the node\n", pod.Name, pod.Spec.NodeName, s.RemotePort)
+	return nil
+}
+
+// checkLocalPort returns an error if the local port of s.LocalAddress cannot be bound, such as when it is already in use
+// or is privileged.
+func (s *Settings) checkLocalPort() error {
+	if s.localPort == "0" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(s.localHost, s.localPort))
+	if errors.Is(err, os.ErrPermission) && isPrivilegedPort(s.localPort) {
+		return &Error{Code: CodeLocalBindFailed, Err: fmt.Errorf("binding to privileged port %s requires elevated privileges: %w", s.localPort, err)}
+	}
+	if err != nil {
+		return &Error{Code: CodeLocalBindFailed, Err: fmt.Errorf("local address %s is not available: %w", s.LocalAddress, err)}
+	}
+	return listener.Close()
+}
+
+// isPrivilegedPort reports whether port is below 1024, so that binding it usually requires elevated privileges.
+func isPrivilegedPort(port string) bool {
+	number, err := strconv.Atoi(port)
+	return err == nil && number > 0 && number < 1024
+}
+
+// PortInfo describes a port declared by a container of a pod.
+type PortInfo struct {
+	Container string
+	Name      string
+	Port      int32
+	Protocol  string
+}
+
+// PodPorts returns the ports declared by the containers of the pod which would be selected for port-forwarding,
+// without starting port-forwarding. As with SelectedPod, it must not be called while port-forwarding with s is running.
+func (s *Settings) PodPorts(ctx context.Context) ([]PortInfo, error) {
+	pod, err := s.SelectedPod(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pod.Ports, nil
+}
+
+// SelectedPod returns the pod which would be selected for port-forwarding, without starting port-forwarding.
+// As it configures the k8s clients of s, it must not be called while port-forwarding with s is running, and returns
+// an error if it is.
+func (s *Settings) SelectedPod(ctx context.Context) (PodInfo, error) {
+	if s.running.Load() {
+		return PodInfo{}, &Error{Code: CodeInvalidSettings, Err: fmt.Errorf("SelectedPod %w", errRunning)}
+	}
+	if err := s.prepare(); err != nil {
+		return PodInfo{}, err
+	}
+
+	if _, err := s.workloadLabelSelector(ctx); err != nil {
+		return PodInfo{}, err
+	}
+
+	pod, _, err := s.selectPod(ctx, s.clientset.CoreV1())
+	if err != nil {
+		return PodInfo{}, err
+	}
+	return newPodInfo(pod), nil
+}
+
+// podPorts returns the ports declared by the containers of pod.
+func podPorts(pod *corev1.Pod) []PortInfo {
+	var ports []PortInfo
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			ports = append(ports, PortInfo{
+				Container: container.Name,
+				Name:      port.Name,
+				Port:      port.ContainerPort,
+				Protocol:  string(port.Protocol),
+			})
+		}
+	}
+	return ports
+}
@@ -0,0 +1,38 @@
+package k8sforward
+
+import (
+	"context"
+	"errors"
+)
+
+// errRebind is returned by forward when it has been stopped by Rebind.
+var errRebind = errors.New("port-forward stopped for rebinding")
+
+// Rebind stops the current port-forwarding started by Init with s, and re-establishes it on the same local address
+// with a newly selected pod. If another matching pod is available, it is preferred over the current one.
+// Rebind blocks until the current port-forwarding has stopped or ctx is done. The new port-forwarding is established asynchronously.
+func (s *Settings) Rebind(ctx context.Context) error {
+	stopped := make(chan struct{})
+
+	select {
+	case s.rebindChannel() <- stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rebindChannel returns the channel on which Rebind requests are received by forward.
+// Each request is a channel which is closed once the current port-forwarding has stopped.
+func (s *Settings) rebindChannel() chan chan struct{} {
+	s.rebindOnce.Do(func() {
+		s.rebind = make(chan chan struct{})
+	})
+	return s.rebind
+}
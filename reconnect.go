@@ -0,0 +1,98 @@
+package k8sforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	defaultReconnectBackoffBase   = time.Second
+	defaultReconnectBackoffMax    = 30 * time.Second
+	defaultReconnectBackoffFactor = 2.0
+)
+
+// backoff computes exponentially increasing, jittered delays between reconnection attempts.
+type backoff struct {
+	base   time.Duration
+	max    time.Duration
+	factor float64
+	next   time.Duration
+}
+
+func (s *Settings) newBackoff() *backoff {
+	return &backoff{
+		base:   s.ReconnectBackoffBase,
+		max:    s.ReconnectBackoffMax,
+		factor: s.ReconnectBackoffFactor,
+		next:   s.ReconnectBackoffBase,
+	}
+}
+
+// reset restores the delay to its base value.
+func (b *backoff) reset() {
+	b.next = b.base
+}
+
+// delay returns the delay before the next attempt, which is between half and all of the current interval,
+// and then increases the interval by the backoff factor up to the maximum.
+func (b *backoff) delay() time.Duration {
+	interval := b.next
+	b.next = min(time.Duration(float64(b.next)*b.factor), b.max)
+
+	half := interval / 2
+	return half + rand.N(interval-half+1)
+}
+
+// reconnect runs port-forwarding, re-establishing it with a newly selected pod after a backoff delay
+// whenever it ends with an error, until ctx is done. With Reconnect, it is re-established once it has been established,
+// and with RetryInitial, it is re-established until it is first established. Otherwise the error is returned.
+func (s *Settings) reconnect(ctx context.Context) error {
+	b := s.newBackoff()
+	everEstablished := false
+	// attempt counts the reconnection attempts since port-forwarding was last established.
+	attempt := 0
+
+	for {
+		established, err := s.forward(ctx)
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+
+		if errors.Is(err, errRebind) {
+			everEstablished = true
+			b.reset()
+			attempt = 0
+			continue
+		}
+
+		if established {
+			everEstablished = true
+			b.reset()
+			attempt = 0
+		}
+		if (!everEstablished && !s.RetryInitial) || (everEstablished && !s.Reconnect) {
+			return err
+		}
+
+		attempt++
+		if s.OnReconnect != nil && !s.OnReconnect(attempt, err) {
+			return fmt.Errorf("reconnection abandoned by OnReconnect: %w", err)
+		}
+
+		delay := b.delay()
+		event := s.newEvent(EventReconnecting)
+		event.Err = err
+		if err := s.writeEvent(event, fmt.Sprintf("%v; reconnecting in %s", err, delay.Round(time.Millisecond))); err != nil {
+			return err
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
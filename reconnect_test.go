@@ -0,0 +1,22 @@
+package k8sforward
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := &backoff{base: time.Second, max: 5 * time.Second, factor: 2, next: time.Second}
+
+	// The interval doubles from the base up to the maximum, and each delay is between half and all of it.
+	for i, interval := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if delay := b.delay(); delay < interval/2 || delay > interval {
+			t.Errorf("delay %d was %s, want from %s to %s", i+1, delay, interval/2, interval)
+		}
+	}
+
+	b.reset()
+	if delay := b.delay(); delay < time.Second/2 || delay > time.Second {
+		t.Errorf("delay after reset was %s, want from %s to %s", delay, time.Second/2, time.Second)
+	}
+}
@@ -0,0 +1,73 @@
+package k8sforward
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ForwardStatus describes port-forwarding currently initiated by Init in this process.
+type ForwardStatus struct {
+	Context         string
+	AppName         string
+	LocalAddress    string
+	LocalSocketPath string
+	RemotePort      string
+	// Pod is the pod currently port-forwarded to, or nil while no pod is, such as while waiting to reconnect.
+	Pod *PodInfo
+	// Active and Ready are as reported by Settings.IsActive and Settings.IsReady.
+	Active bool
+	Ready  bool
+}
+
+// registry holds the Settings of port-forwarding initiated by Init which has not yet returned.
+var registry = struct {
+	sync.Mutex
+	forwards map[*Settings]struct{}
+}{forwards: map[*Settings]struct{}{}}
+
+func register(s *Settings) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.forwards[s] = struct{}{}
+}
+
+func unregister(s *Settings) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.forwards, s)
+}
+
+// Forwards returns the status of each port-forwarding initiated by Init in this process which has not yet returned,
+// ordered by local address, such as for reporting on many concurrent forwards.
+func Forwards() []ForwardStatus {
+	registry.Lock()
+	defer registry.Unlock()
+
+	statuses := make([]ForwardStatus, 0, len(registry.forwards))
+	for s := range registry.forwards {
+		var pod *PodInfo
+		if current := s.currentPod.Load(); current != nil {
+			// Copied, with its labels and ports, so that callers cannot modify the PodInfo of the forward itself.
+			podInfo := *current
+			podInfo.Labels = maps.Clone(current.Labels)
+			podInfo.Ports = slices.Clone(current.Ports)
+			pod = &podInfo
+		}
+		statuses = append(statuses, ForwardStatus{
+			Context:         s.ContextName,
+			AppName:         s.AppName,
+			LocalAddress:    s.LocalAddress,
+			LocalSocketPath: s.LocalSocketPath,
+			RemotePort:      s.RemotePort,
+			Pod:             pod,
+			Active:          s.IsActive(),
+			Ready:           s.IsReady(),
+		})
+	}
+	slices.SortFunc(statuses, func(a, b ForwardStatus) int {
+		return strings.Compare(a.LocalAddress, b.LocalAddress)
+	})
+	return statuses
+}
@@ -0,0 +1,86 @@
+package k8sforward
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// maxRejections is the maximum number of rejected pods described in an error.
+const maxRejections = 10
+
+// explainRejections adds to missingErr why each pod matching labelSelector, whatever its phase, was rejected, so that
+// pods which exist but are not usable can be diagnosed. If there are no such pods, or they cannot be listed,
+// missingErr is returned unchanged.
+func (s *Settings) explainRejections(ctx context.Context, podClient corev1client.CoreV1Interface, labelSelector string, missingErr error) error {
+	var pods []corev1.Pod
+	if len(s.Pods) > 0 {
+		selector, err := labels.Parse(labelSelector)
+		if err != nil {
+			return missingErr
+		}
+		pods = s.matchingProvidedPods(selector)
+	} else {
+		namespace := s.namespace
+		if s.AllNamespaces {
+			namespace = metav1.NamespaceAll
+		}
+		list, err := podClient.Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labelSelector,
+			FieldSelector: s.fieldSelector(false),
+			Limit:         maxRejections,
+		})
+		if err != nil {
+			return missingErr
+		}
+		pods = list.Items
+	}
+
+	var rejections []string
+	for i := range pods {
+		if s.Selector == nil && s.AppNamePrefix && s.AppName != "" && !strings.HasPrefix(pods[i].Labels[s.AppLabelKey], s.AppName) {
+			// Pods of other apps are listed when selecting by prefix, so are not candidates.
+			continue
+		}
+		if reason := s.rejection(&pods[i], time.Now()); reason != "" {
+			rejections = append(rejections, fmt.Sprintf("%s: %s", pods[i].Name, reason))
+		}
+		if len(rejections) == maxRejections {
+			break
+		}
+	}
+	if len(rejections) == 0 {
+		return missingErr
+	}
+	return fmt.Errorf("%w; rejected pods: %s", missingErr, strings.Join(rejections, ", "))
+}
+
+// rejection returns why pod is not usable for port-forwarding at now, or an empty string if it is.
+func (s *Settings) rejection(pod *corev1.Pod, now time.Time) string {
+	if pod.DeletionTimestamp != nil {
+		return "Terminating"
+	}
+	if pod.Status.Phase != corev1.PodRunning && !s.IncludeNonRunning {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
+				return fmt.Sprintf("%s (%s)", pod.Status.Phase, status.State.Waiting.Reason)
+			}
+		}
+		return string(pod.Status.Phase)
+	}
+	if s.Selector == nil {
+		if !matchesAnnotations(pod.Annotations, s.AnnotationSelector) {
+			return "missing annotations"
+		}
+	}
+	if s.MinPodAge > 0 && len(filterMinPodAge([]corev1.Pod{*pod}, s.MinPodAge, now)) == 0 {
+		return fmt.Sprintf("younger than %s", s.MinPodAge)
+	}
+	return ""
+}
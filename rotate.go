@@ -0,0 +1,114 @@
+package k8sforward
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// defaultNotReadyThreshold is the default duration a pod may not be ready for before rotating away from it.
+const defaultNotReadyThreshold = 10 * time.Second
+
+// rotateOnNotReady watches pod while it is forwarded to, and rebinds to another ready pod once pod has not been ready for
+// s.NotReadyThreshold. If there is no other ready pod, it keeps watching. It returns once ctx is done or it has rebound.
+func (s *Settings) rotateOnNotReady(ctx context.Context, pod *corev1.Pod) {
+	var notReady <-chan time.Time
+	resourceVersion := pod.ResourceVersion
+
+	for {
+		watcher, err := s.streamClientset.CoreV1().Pods(pod.Namespace).Watch(ctx, metav1.ListOptions{
+			FieldSelector:   fmt.Sprintf("metadata.name=%s", pod.Name),
+			ResourceVersion: resourceVersion,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				_, _ = fmt.Fprintf(s.ErrOut, "Error watching the readiness of pod %s: %v\n", pod.Name, err)
+			}
+			return
+		}
+
+		watching := true
+		for watching {
+			select {
+			case event, ok := <-watcher.ResultChan():
+				if !ok {
+					// Watches are ended by the API server after a while, so watch again from the last version seen.
+					watching = false
+					break
+				}
+				if event.Type == watch.Error {
+					// The last version seen may have expired, so watch again from the current state of the pod.
+					resourceVersion = ""
+					continue
+				}
+				watched, ok := event.Object.(*corev1.Pod)
+				if !ok {
+					continue
+				}
+				resourceVersion = watched.ResourceVersion
+				switch {
+				case isPodReady(watched):
+					notReady = nil
+				case notReady == nil:
+					notReady = time.After(s.NotReadyThreshold)
+				}
+			case <-notReady:
+				if available, err := s.readyAlternative(ctx, pod); err != nil || !available {
+					if err != nil {
+						s.verbosef("Could not check for another ready pod to rotate to from pod %s: %v", pod.Name, err)
+					} else {
+						s.verbosef("Pod %s has not been ready for %s, but no other ready pod is available to rotate to", pod.Name, s.NotReadyThreshold)
+					}
+					// Check again after another threshold, in case another pod has become ready.
+					notReady = time.After(s.NotReadyThreshold)
+					continue
+				}
+				watcher.Stop()
+				_, _ = fmt.Fprintf(s.ErrOut, "Pod %s has not been ready for %s, so rotating to another pod\n", pod.Name, s.NotReadyThreshold)
+				if err := s.Rebind(ctx); err != nil && ctx.Err() == nil {
+					_, _ = fmt.Fprintf(s.ErrOut, "Error rotating from pod %s: %v\n", pod.Name, err)
+				}
+				return
+			case <-ctx.Done():
+				watcher.Stop()
+				return
+			}
+		}
+		watcher.Stop()
+	}
+}
+
+// readyAlternative reports whether a ready pod other than pod matches the settings, so that rotating from pod would not
+// select it again or select another pod which is not ready.
+func (s *Settings) readyAlternative(ctx context.Context, pod *corev1.Pod) (bool, error) {
+	if s.StatefulSetName != "" || s.podName != "" {
+		// Only one pod can be selected.
+		return false, nil
+	}
+
+	labelSelector, _ := s.podLabelSelector()
+	var pods []corev1.Pod
+	var err error
+	if len(s.Pods) > 0 {
+		pods, err = s.providedPods(labelSelector)
+	} else {
+		pods, err = s.listPods(ctx, s.clientset.CoreV1(), labelSelector)
+	}
+	if err == nil && s.ReadyEndpointsOnly {
+		pods, err = s.filterReadyEndpoints(ctx, pods)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, candidate := range readyPods(pods) {
+		if candidate.Name != pod.Name || candidate.Namespace != pod.Namespace {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,10 @@
+package k8sforward
+
+// PodSelector selects pods with raw k8s label and field selectors.
+type PodSelector struct {
+	// LabelSelector (optional) is a k8s label selector, such as "app=api,tier in (web,worker)".
+	LabelSelector string
+	// FieldSelector (optional) is a k8s field selector, such as "spec.nodeName=node-1". It cannot select for status.phase,
+	// as only running pods are selected, unless Settings.IncludeNonRunning is given.
+	FieldSelector string
+}
@@ -0,0 +1,163 @@
+package k8sforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// freeLocalAddress returns a loopback address with a TCP port which is currently free, for relaying to from LocalSocketPath
+// or, with DeferAccept or Trace, for port-forwarding to listen on.
+func freeLocalAddress() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("error finding a free local TCP port: %w", err)
+	}
+	defer func() {
+		_ = listener.Close()
+	}()
+	return listener.Addr().String(), nil
+}
+
+// listenUnix listens on s.LocalSocketPath, replacing any stale socket file left there.
+func (s *Settings) listenUnix() (net.Listener, error) {
+	if info, err := os.Lstat(s.LocalSocketPath); err == nil && info.Mode().Type() == fs.ModeSocket {
+		if err = os.Remove(s.LocalSocketPath); err != nil {
+			return nil, fmt.Errorf("error removing stale socket %s: %w", s.LocalSocketPath, err)
+		}
+	}
+
+	var listener net.Listener
+	var err error
+	if s.ListenBacklog > 0 {
+		listener, err = listenUnixBacklog(s.LocalSocketPath, s.ListenBacklog)
+	} else {
+		listener, err = net.Listen("unix", s.LocalSocketPath)
+	}
+	if err != nil {
+		return nil, &Error{Code: CodeLocalBindFailed, Err: fmt.Errorf("error listening on socket %s: %w", s.LocalSocketPath, err)}
+	}
+	return listener, nil
+}
+
+// relayListener accepts connections on listener and relays each to the forward's local TCP address until ctx is done.
+// With DeferAccept, connections are not accepted until port-forwarding is first ready.
+// Each relay is added to relays until it finishes.
+func (s *Settings) relayListener(ctx context.Context, listener net.Listener, relays *sync.WaitGroup) {
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	if s.DeferAccept {
+		select {
+		case <-s.firstReady:
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				_, _ = fmt.Fprintf(s.ErrOut, "error accepting connection on %s: %v\n", listener.Addr(), err)
+			}
+			return
+		}
+		relays.Add(1)
+		go func() {
+			defer relays.Done()
+			s.relay(conn)
+		}()
+	}
+}
+
+// halfCloser is a connection whose writing side can be closed alone, such as *net.TCPConn and *net.UnixConn.
+type halfCloser interface {
+	CloseWrite() error
+}
+
+// relay copies data between conn and a new connection to the forward's local TCP address until either side closes.
+// With Trace, the relayed connection is counted in s.TraceStats.
+func (s *Settings) relay(conn net.Conn) {
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	dialStart := time.Now()
+	tcpConn, err := net.Dial("tcp", s.forwardAddress)
+	if err != nil {
+		_, _ = fmt.Fprintf(s.ErrOut, "error relaying from %s to %s: %v\n", conn.LocalAddr(), s.forwardAddress, err)
+		return
+	}
+	defer func() {
+		_ = tcpConn.Close()
+	}()
+
+	var toPod io.Writer = tcpConn
+	var fromPod io.Writer = conn
+	if s.Trace {
+		connectedAt := time.Now()
+		s.trace.dialTime.Add(int64(connectedAt.Sub(dialStart)))
+		s.trace.connections.Add(1)
+		s.trace.activeConnections.Add(1)
+		defer func() {
+			s.trace.activeConnections.Add(-1)
+			s.trace.connectionTime.Add(int64(time.Since(connectedAt)))
+		}()
+		toPod = countingWriter{w: tcpConn, count: &s.trace.bytesSent}
+		fromPod = countingWriter{w: conn, count: &s.trace.bytesReceived}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(toPod, conn)
+		_ = tcpConn.(halfCloser).CloseWrite()
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(fromPod, tcpConn)
+		if closer, ok := conn.(halfCloser); ok {
+			_ = closer.CloseWrite()
+		}
+	}()
+	wg.Wait()
+}
+
+// listenRelayed listens on s.LocalAddress for relaying to the forward, with DeferAccept or Trace.
+func (s *Settings) listenRelayed() (net.Listener, error) {
+	listener, err := net.Listen("tcp", s.LocalAddress)
+	if err != nil {
+		return nil, &Error{Code: CodeLocalBindFailed, Err: fmt.Errorf("error listening on %s: %w", s.LocalAddress, err)}
+	}
+	return listener, nil
+}
+
+// draining reports whether relayed connections are drained on stopping.
+func (s *Settings) draining() bool {
+	return s.DrainTimeout > 0 && (s.LocalSocketPath != "" || s.relaying())
+}
+
+// drain waits for at most s.DrainTimeout for relays to finish.
+func (s *Settings) drain(relays *sync.WaitGroup) {
+	drained := make(chan struct{})
+	go func() {
+		relays.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(s.DrainTimeout):
+		s.verbosef("Relayed connections did not finish within %s", s.DrainTimeout)
+	}
+}
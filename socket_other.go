@@ -0,0 +1,11 @@
+//go:build !unix
+
+package k8sforward
+
+import "net"
+
+// listenUnixBacklog listens on the Unix domain socket path. The listen backlog cannot be set on this platform, so the
+// system default is used.
+func listenUnixBacklog(path string, _ int) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
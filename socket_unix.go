@@ -0,0 +1,41 @@
+//go:build unix
+
+package k8sforward
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenUnixBacklog listens on the Unix domain socket path with a listen backlog of backlog connections.
+func listenUnixBacklog(path string, backlog int) (net.Listener, error) {
+	fd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	syscall.CloseOnExec(fd)
+
+	file := os.NewFile(uintptr(fd), path)
+	defer func() {
+		// net.FileListener duplicates the file descriptor, so this one is always closed.
+		_ = file.Close()
+	}()
+
+	if err = syscall.Bind(fd, &syscall.SockaddrUnix{Name: path}); err != nil {
+		return nil, os.NewSyscallError("bind", err)
+	}
+	if err = syscall.Listen(fd, backlog); err != nil {
+		_ = os.Remove(path)
+		return nil, os.NewSyscallError("listen", err)
+	}
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, err
+	}
+	// Remove the socket file on closing, as net.Listen does for the listeners it creates.
+	listener.(*net.UnixListener).SetUnlinkOnClose(true)
+	return listener, nil
+}
@@ -0,0 +1,34 @@
+package k8sforward
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	// stdout and stderr are the defaults for Settings.Out and Settings.ErrOut, shared so that
+	// concurrent forwards using the defaults do not interleave their output.
+	stdout = NewSyncWriter(os.Stdout)
+	stderr = NewSyncWriter(os.Stderr)
+)
+
+// SyncWriter is an io.Writer which serializes writes to an underlying io.Writer, so that each write is atomic.
+// All output from k8sforward is written a line at a time, so sharing one SyncWriter as Out or ErrOut between
+// the Settings of concurrent forwards keeps their lines from interleaving.
+type SyncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewSyncWriter returns a SyncWriter writing to w.
+func NewSyncWriter(w io.Writer) *SyncWriter {
+	return &SyncWriter{w: w}
+}
+
+// Write writes p to the underlying io.Writer, waiting for any concurrent write to complete first.
+func (w *SyncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
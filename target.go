@@ -0,0 +1,60 @@
+package k8sforward
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// applyTarget sets the pod, Service or workload to select pods by from s.Target, which is in kubectl's kind/name format.
+func (s *Settings) applyTarget() error {
+	if s.targetApplied {
+		return nil
+	}
+
+	kind, name, ok := strings.Cut(s.Target, "/")
+	if !ok || kind == "" || name == "" {
+		return fmt.Errorf("target must be in kind/name format, such as 'deployment/api', but was '%s'", s.Target)
+	}
+	if s.AppName != "" || s.Selector != nil || s.StatefulSetName != "" || s.JobName != "" || s.DeploymentName != "" {
+		return fmt.Errorf("target cannot be given with an app name, a selector, or a StatefulSet, Job or Deployment name")
+	}
+
+	switch strings.ToLower(kind) {
+	case "pod", "pods", "po":
+		s.podName = name
+	case "service", "services", "svc":
+		s.serviceName = name
+	case "deployment", "deployments", "deploy":
+		s.DeploymentName = name
+	case "statefulset", "statefulsets", "sts":
+		s.StatefulSetName = name
+	case "job", "jobs":
+		s.JobName = name
+	default:
+		return fmt.Errorf("target kind '%s' is not one of pod, service, deployment, statefulset or job", kind)
+	}
+
+	s.targetApplied = true
+	return nil
+}
+
+// serviceLabelSelector returns the label selector for the pods of the Service of s.Target.
+func (s *Settings) serviceLabelSelector(ctx context.Context) (string, error) {
+	service, err := s.clientset.CoreV1().Services(s.namespace).Get(ctx, s.serviceName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", &Error{Code: CodeNoPods, Err: fmt.Errorf("no Service '%s' found in '%s' context", s.serviceName, s.ContextName)}
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting Service '%s': %w", s.serviceName, err)
+	}
+
+	if len(service.Spec.Selector) == 0 {
+		return "", fmt.Errorf("no selector for the pods of Service '%s'", s.serviceName)
+	}
+	return labels.SelectorFromSet(service.Spec.Selector).String(), nil
+}
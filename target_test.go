@@ -0,0 +1,73 @@
+package k8sforward
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestApplyTarget(t *testing.T) {
+	tests := []struct {
+		name      string
+		target    string
+		configure func(s *Settings)
+		want      func(s *Settings) string
+		wantName  string
+		wantErr   bool
+	}{
+		{name: "pod", target: "pod/web-a", want: func(s *Settings) string { return s.podName }, wantName: "web-a"},
+		{name: "pod short kind", target: "po/web-a", want: func(s *Settings) string { return s.podName }, wantName: "web-a"},
+		{name: "Service", target: "svc/web", want: func(s *Settings) string { return s.serviceName }, wantName: "web"},
+		{name: "Deployment", target: "Deployment/web", want: func(s *Settings) string { return s.DeploymentName }, wantName: "web"},
+		{name: "StatefulSet", target: "sts/db", want: func(s *Settings) string { return s.StatefulSetName }, wantName: "db"},
+		{name: "Job", target: "jobs/migrate", want: func(s *Settings) string { return s.JobName }, wantName: "migrate"},
+		{name: "unknown kind", target: "daemonset/agent", wantErr: true},
+		{name: "missing name", target: "pod/", wantErr: true},
+		{name: "missing kind", target: "web", wantErr: true},
+		{
+			name:      "with an app name",
+			target:    "pod/web-a",
+			configure: func(s *Settings) { s.AppName = "web" },
+			wantErr:   true,
+		},
+		{
+			name:      "with a Deployment name",
+			target:    "svc/web",
+			configure: func(s *Settings) { s.DeploymentName = "web" },
+			wantErr:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &Settings{Target: test.target}
+			if test.configure != nil {
+				test.configure(s)
+			}
+
+			err := s.applyTarget()
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("applyTarget of '%s' returned no error, want one", test.target)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyTarget of '%s' returned error: %v", test.target, err)
+			}
+			if got := test.want(s); got != test.wantName {
+				t.Errorf("applyTarget of '%s' set name '%s', want '%s'", test.target, got, test.wantName)
+			}
+		})
+	}
+}
+
+func TestValidateAllNamespacesWithTarget(t *testing.T) {
+	for _, target := range []string{"pod/web-a", "svc/web"} {
+		s := &Settings{ContextName: "dev", Target: target, AllNamespaces: true, LocalAddress: "localhost:8080", RemotePort: "80"}
+		if !slices.ContainsFunc(s.ValidateAll(), func(err error) bool {
+			return strings.Contains(err.Error(), "all namespaces cannot be used with a pod or Service target")
+		}) {
+			t.Errorf("validating all namespaces with target '%s' did not reject them", target)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package k8sforward
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// TraceStats are transfer statistics of the connections relayed through port-forwarding with Settings.Trace.
+type TraceStats struct {
+	// Connections is how many connections have been relayed, including any still open.
+	Connections int64
+	// ActiveConnections is how many relayed connections are currently open.
+	ActiveConnections int64
+	// BytesSent is how many bytes have been sent towards the pod.
+	BytesSent int64
+	// BytesReceived is how many bytes have been received from the pod.
+	BytesReceived int64
+	// DialTime is the total time taken to connect to the port-forwarder, which excludes the time taken by the pod.
+	DialTime time.Duration
+	// ConnectionTime is the total time for which relayed connections have been open, excluding any still open.
+	ConnectionTime time.Duration
+}
+
+// traceCounters accumulates TraceStats concurrently.
+type traceCounters struct {
+	connections       atomic.Int64
+	activeConnections atomic.Int64
+	bytesSent         atomic.Int64
+	bytesReceived     atomic.Int64
+	dialTime          atomic.Int64
+	connectionTime    atomic.Int64
+}
+
+// TraceStats returns the transfer statistics of the connections relayed so far with Trace. It may be called
+// while port-forwarding is running or after it has stopped. Without Trace, the statistics are all zero.
+func (s *Settings) TraceStats() TraceStats {
+	return TraceStats{
+		Connections:       s.trace.connections.Load(),
+		ActiveConnections: s.trace.activeConnections.Load(),
+		BytesSent:         s.trace.bytesSent.Load(),
+		BytesReceived:     s.trace.bytesReceived.Load(),
+		DialTime:          time.Duration(s.trace.dialTime.Load()),
+		ConnectionTime:    time.Duration(s.trace.connectionTime.Load()),
+	}
+}
+
+// relaying reports whether port-forwarding listens on s.forwardAddress, with LocalAddress relayed to it.
+func (s *Settings) relaying() bool {
+	return s.DeferAccept || s.Trace
+}
+
+// countingWriter is an io.Writer adding the number of bytes written to count.
+type countingWriter struct {
+	w     io.Writer
+	count *atomic.Int64
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.count.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,126 @@
+package k8sforward
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+	kubectlportforward "k8s.io/kubectl/pkg/cmd/portforward"
+	cmdutil "k8s.io/kubectl/pkg/cmd/util"
+)
+
+const (
+	// TransportAuto tries WebSocket port-forwarding, falling back to SPDY if the API server or a proxy does not support it.
+	// This is the default, and is the behaviour of kubectl.
+	TransportAuto = "auto"
+	// TransportSPDY port-forwards over SPDY only.
+	TransportSPDY = "spdy"
+	// TransportWebSocket port-forwards over WebSocket only, which some proxies that break SPDY allow.
+	TransportWebSocket = "websocket"
+)
+
+// transportForwarder port-forwards with the dialer for f.transport, replacing the port-forwarder of kubectl, so that
+// the transport can be chosen and the port-forwarder's local listeners can be closed if it does not stop.
+type transportForwarder struct {
+	transport string
+	out       io.Writer
+	errOut    io.Writer
+
+	mu        sync.Mutex
+	forwarder *portforward.PortForwarder
+}
+
+// ForwardPorts port-forwards according to opts, over f.transport.
+func (f *transportForwarder) ForwardPorts(method string, url *url.URL, opts kubectlportforward.PortForwardOptions) error {
+	var dialer httpstream.Dialer
+	if f.transport == TransportWebSocket {
+		var err error
+		if dialer, err = portforward.NewSPDYOverWebsocketDialer(url, opts.Config); err != nil {
+			return err
+		}
+	} else {
+		transport, upgrader, err := spdy.RoundTripperFor(opts.Config)
+		if err != nil {
+			return err
+		}
+		dialer = spdy.NewDialer(upgrader, &http.Client{Transport: transport}, method, url)
+
+		if f.transport == TransportAuto && !cmdutil.PortForwardWebsockets.IsDisabled() {
+			// As kubectl does, try WebSocket first, falling back to SPDY.
+			websocketDialer, err := portforward.NewSPDYOverWebsocketDialer(url, opts.Config)
+			if err != nil {
+				return err
+			}
+			dialer = portforward.NewFallbackDialer(websocketDialer, dialer, func(err error) bool {
+				return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+			})
+		}
+	}
+
+	fw, err := portforward.NewOnAddresses(dialer, opts.Address, opts.Ports, opts.StopChannel, opts.ReadyChannel, f.out, f.errOut)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.forwarder = fw
+	f.mu.Unlock()
+	return fw.ForwardPorts()
+}
+
+// closeListeners closes the local listeners of the port-forwarder, if it has been created, so that the local address
+// is released even if the port-forwarder is blocked closing its connection to the pod.
+func (f *transportForwarder) closeListeners() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.forwarder != nil {
+		f.forwarder.Close()
+	}
+}
+
+// runPortForward port-forwards according to opts until ctx is done or forwarding fails, closing opts.StopChannel once
+// either happens. It does as kubectl's RunPortForwardContext does, including stopping on an interrupt signal if
+// onInterrupt, which is not wanted when draining, as it would cut relayed connections before they could be drained.
+func runPortForward(ctx context.Context, opts *kubectlportforward.PortForwardOptions, onInterrupt bool) error {
+	pod, err := opts.PodClient.Pods(opts.Namespace).Get(ctx, opts.PodName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if pod.Status.Phase != corev1.PodRunning {
+		return fmt.Errorf("unable to forward port because pod is not running. Current status=%v", pod.Status.Phase)
+	}
+
+	var signals chan os.Signal
+	if onInterrupt {
+		signals = make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		defer signal.Stop(signals)
+	}
+
+	returnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-signals:
+		case <-returnCtx.Done():
+		}
+		close(opts.StopChannel)
+	}()
+
+	req := opts.RESTClient.Post().
+		Resource("pods").
+		Namespace(opts.Namespace).
+		Name(pod.Name).
+		SubResource("portforward")
+
+	return opts.PortForwarder.ForwardPorts("POST", req.URL(), *opts)
+}
@@ -1,9 +1,18 @@
 package k8sforward
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net"
+	"net/url"
 	"strconv"
 	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 func validateNonEmptyString(name, value string) error {
@@ -13,18 +22,22 @@ func validateNonEmptyString(name, value string) error {
 	return nil
 }
 
-func validateTCPPort(name, portStr string) error {
+// validateTCPPort validates portStr as a TCP port number from minPort to 65535.
+// The minimum is 0 for a local port, meaning OS-assigned, but 1 for a remote port, as there is no OS-assigned remote port.
+func validateTCPPort(name, portStr string, minPort int) error {
 	if err := validateNonEmptyString(name, portStr); err != nil {
 		return err
 	}
 	port, err := strconv.Atoi(portStr)
-	if err == nil && port >= 0 && port <= 65535 {
+	if err == nil && port >= minPort && port <= 65535 {
 		return nil
 	}
-	return fmt.Errorf("%s must be an integer from 0 to 65535 but was '%s'", name, portStr)
+	return fmt.Errorf("%s must be an integer from %d to 65535 but was '%s'", name, minPort, portStr)
 }
 
-func validateLocalAddress(localAddress string) ([]string, error) {
+// validateLocalAddress returns the host and port of localAddress. Its host must not be the unspecified address, which
+// listens on all interfaces, unless allowPublicBind.
+func validateLocalAddress(localAddress string, allowPublicBind bool) ([]string, error) {
 	if err := validateNonEmptyString("local address", localAddress); err != nil {
 		return nil, err
 	}
@@ -35,8 +48,240 @@ func validateLocalAddress(localAddress string) ([]string, error) {
 	if err := validateNonEmptyString("local host", addressParts[0]); err != nil {
 		return nil, err
 	}
-	if err := validateTCPPort("local port", addressParts[1]); err != nil {
+	// The port-forwarder only listens on localhost or IP addresses.
+	if addressParts[0] != "localhost" && net.ParseIP(addressParts[0]) == nil {
+		return nil, fmt.Errorf("local host must be 'localhost' or an IP address but was '%s'", addressParts[0])
+	}
+	if ip := net.ParseIP(addressParts[0]); ip != nil && ip.IsUnspecified() && !allowPublicBind {
+		return nil, fmt.Errorf("local host '%s' listens on all network interfaces, exposing port-forwarding to anyone who can reach "+
+			"this machine, so it requires AllowPublicBind", addressParts[0])
+	}
+	if err := validateTCPPort("local port", addressParts[1], 0); err != nil {
 		return nil, err
 	}
 	return addressParts, nil
 }
+
+// validateFieldSelector checks fieldSelector, which must not select on status.phase unless includeNonRunning, as
+// running pods are otherwise selected already.
+func validateFieldSelector(fieldSelector string, includeNonRunning bool) error {
+	if fieldSelector == "" {
+		return nil
+	}
+	selector, err := fields.ParseSelector(fieldSelector)
+	if err != nil {
+		return fmt.Errorf("field selector '%s' is invalid: %w", fieldSelector, err)
+	}
+	for _, requirement := range selector.Requirements() {
+		if requirement.Field == "status.phase" && !includeNonRunning {
+			return fmt.Errorf("field selector '%s' conflicts with the default status.phase=Running filter", fieldSelector)
+		}
+	}
+	return nil
+}
+
+func validatePodSelector(selector *PodSelector, includeNonRunning bool) error {
+	if _, err := labels.Parse(selector.LabelSelector); err != nil {
+		return fmt.Errorf("label selector '%s' is invalid: %w", selector.LabelSelector, err)
+	}
+	return validateFieldSelector(selector.FieldSelector, includeNonRunning)
+}
+
+func validateOutputFormat(outputFormat string) error {
+	switch outputFormat {
+	case OutputFormatText, OutputFormatJSON:
+		return nil
+	}
+	return fmt.Errorf("output format must be '%s' or '%s' but was '%s'", OutputFormatText, OutputFormatJSON, outputFormat)
+}
+
+func validateTransport(transport string) error {
+	switch transport {
+	case TransportAuto, TransportSPDY, TransportWebSocket:
+		return nil
+	}
+	return fmt.Errorf("transport must be '%s', '%s' or '%s' but was '%s'", TransportAuto, TransportSPDY, TransportWebSocket, transport)
+}
+
+func validateNonNegativeDuration(name string, value time.Duration) error {
+	if value < 0 {
+		return fmt.Errorf("%s must not be negative but was %s", name, value)
+	}
+	return nil
+}
+
+func validateReconnectBackoff(base, maximum time.Duration, factor float64) error {
+	if base <= 0 {
+		return fmt.Errorf("reconnect backoff base must be positive but was %s", base)
+	}
+	if maximum < base {
+		return fmt.Errorf("reconnect backoff max must be at least the base of %s but was %s", base, maximum)
+	}
+	if factor < 1 {
+		return fmt.Errorf("reconnect backoff factor must be at least 1 but was %g", factor)
+	}
+	return nil
+}
+
+func validateStatefulSetPod(appName string, allNamespaces bool, ordinal int) error {
+	if appName != "" {
+		return fmt.Errorf("k8s app name and StatefulSet name cannot both be given")
+	}
+	if allNamespaces {
+		return fmt.Errorf("all namespaces cannot be used with a StatefulSet name")
+	}
+	if ordinal < 0 {
+		return fmt.Errorf("StatefulSet ordinal must not be negative but was %d", ordinal)
+	}
+	return nil
+}
+
+func validateRateLimits(qps float32, burst int) error {
+	if qps < 0 {
+		return fmt.Errorf("QPS must not be negative but was %g", qps)
+	}
+	if burst < 0 {
+		return fmt.Errorf("burst must not be negative but was %d", burst)
+	}
+	return nil
+}
+
+// validateTLSData checks that the PEM-encoded client certificate and key are given together and form a key pair, and that
+// the PEM-encoded certificate authority bundle contains a certificate and is not given with insecure.
+func validateTLSData(certData, keyData, caData []byte, insecure bool) error {
+	if len(certData) > 0 != (len(keyData) > 0) {
+		return fmt.Errorf("client certificate data and client key data must be given together")
+	}
+	if len(certData) > 0 {
+		if _, err := tls.X509KeyPair(certData, keyData); err != nil {
+			return fmt.Errorf("client certificate and key data are invalid: %w", err)
+		}
+	}
+	if len(caData) > 0 {
+		if insecure {
+			return fmt.Errorf("certificate authority data cannot be given with insecure skipping of TLS verification")
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(caData) {
+			return fmt.Errorf("certificate authority data contains no PEM-encoded certificates")
+		}
+	}
+	return nil
+}
+
+// validateAPIServer checks that apiServer is an http or https URL with a host, and that no kubeconfig settings are given with it.
+func validateAPIServer(apiServer, kubeconfigPath, authInfoOverride, clusterOverride string) error {
+	parsed, err := url.Parse(apiServer)
+	if err != nil {
+		return fmt.Errorf("k8s API server URL '%s' is invalid: %w", apiServer, err)
+	}
+	if parsed.Scheme != "https" && parsed.Scheme != "http" {
+		return fmt.Errorf("k8s API server URL must use the http or https scheme but was '%s'", apiServer)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("k8s API server URL must include a host but was '%s'", apiServer)
+	}
+	if kubeconfigPath != "" || authInfoOverride != "" || clusterOverride != "" {
+		return fmt.Errorf("kubeconfig path, user and cluster cannot be given with a k8s API server URL")
+	}
+	return nil
+}
+
+func validateNamespace(namespace string) error {
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return fmt.Errorf("namespace '%s' is invalid: %s", namespace, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func validateProxyURL(proxyURL string) (*url.URL, error) {
+	parsed, err := url.Parse(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("proxy URL '%s' is invalid: %w", proxyURL, err)
+	}
+	switch parsed.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy URL must have an http, https or socks5 scheme but was '%s'", proxyURL)
+	}
+	if parsed.Host == "" {
+		return nil, fmt.Errorf("proxy URL must include a host but was '%s'", proxyURL)
+	}
+	return parsed, nil
+}
+
+func validateLabelKey(name, key string) error {
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return fmt.Errorf("%s '%s' is invalid: %s", name, key, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// validateVersionNames returns the versions of the comma-separated versionNames, each of which must be a valid label value.
+func validateVersionNames(versionNames string) ([]string, error) {
+	names := strings.Split(versionNames, ",")
+	for _, name := range names {
+		if name == "" {
+			return nil, fmt.Errorf("version names '%s' include an empty version", versionNames)
+		}
+		if errs := validation.IsValidLabelValue(name); len(errs) > 0 {
+			return nil, fmt.Errorf("version name '%s' is invalid: %s", name, strings.Join(errs, "; "))
+		}
+	}
+	return names, nil
+}
+
+// validateAppName returns an error if appName cannot be used as the value of the app label, so that it would make a
+// malformed label selector. With prefix, appName need only be the start of a valid label value.
+func validateAppName(appName string, prefix bool) error {
+	if !prefix {
+		if errs := validation.IsValidLabelValue(appName); len(errs) > 0 {
+			return fmt.Errorf("k8s app name '%s' is not a valid label value: %s", appName, strings.Join(errs, "; "))
+		}
+		return nil
+	}
+
+	if len(appName) > validation.LabelValueMaxLength {
+		return fmt.Errorf("k8s app name prefix '%s' is longer than a label value may be, which is %d characters", appName, validation.LabelValueMaxLength)
+	}
+	for _, r := range appName {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("k8s app name prefix '%s' is not the start of a valid label value, which may only contain alphanumeric characters, '-', '_' or '.'", appName)
+		}
+	}
+	if first := appName[0]; first == '-' || first == '_' || first == '.' {
+		return fmt.Errorf("k8s app name prefix '%s' is not the start of a valid label value, which must start with an alphanumeric character", appName)
+	}
+	return nil
+}
+
+func validateAnnotationSelector(annotations map[string]string) error {
+	for key := range annotations {
+		if err := validateLabelKey("annotation key", key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateWorkload(kind, appName string, allNamespaces bool) error {
+	if appName != "" {
+		return fmt.Errorf("k8s app name and %s name cannot both be given", kind)
+	}
+	if allNamespaces {
+		return fmt.Errorf("all namespaces cannot be used with a %s name", kind)
+	}
+	return nil
+}
+
+func validateSingleWorkload(names ...string) error {
+	given := 0
+	for _, name := range names {
+		if name != "" {
+			given++
+		}
+	}
+	if given > 1 {
+		return fmt.Errorf("only one of StatefulSet, Job and Deployment names can be given")
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package k8sforward
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTCPPort(t *testing.T) {
+	tests := []struct {
+		name    string
+		port    string
+		minPort int
+		wantErr bool
+	}{
+		{name: "lowest remote port", port: "1", minPort: 1},
+		{name: "highest port", port: "65535", minPort: 1},
+		{name: "OS-assigned local port", port: "0", minPort: 0},
+		{name: "zero remote port", port: "0", minPort: 1, wantErr: true},
+		{name: "above highest port", port: "65536", minPort: 0, wantErr: true},
+		{name: "negative port", port: "-1", minPort: 0, wantErr: true},
+		{name: "not a number", port: "http", minPort: 1, wantErr: true},
+		{name: "empty", port: "", minPort: 1, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateTCPPort("port", test.port, test.minPort)
+			if (err != nil) != test.wantErr {
+				t.Errorf("validateTCPPort(%q, %d) returned error %v, want error: %t", test.port, test.minPort, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateLocalAddress(t *testing.T) {
+	tests := []struct {
+		name            string
+		localAddress    string
+		allowPublicBind bool
+		wantErr         bool
+	}{
+		{name: "localhost", localAddress: "localhost:8080"},
+		{name: "loopback IP", localAddress: "127.0.0.1:8080"},
+		{name: "OS-assigned port", localAddress: "localhost:0"},
+		{name: "all interfaces without AllowPublicBind", localAddress: "0.0.0.0:8080", wantErr: true},
+		{name: "all interfaces with AllowPublicBind", localAddress: "0.0.0.0:8080", allowPublicBind: true},
+		{name: "host name", localAddress: "example.com:8080", wantErr: true},
+		{name: "missing host", localAddress: ":8080", wantErr: true},
+		{name: "missing port", localAddress: "localhost", wantErr: true},
+		{name: "invalid port", localAddress: "localhost:65536", wantErr: true},
+		{name: "empty", localAddress: "", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := validateLocalAddress(test.localAddress, test.allowPublicBind)
+			if (err != nil) != test.wantErr {
+				t.Errorf("validateLocalAddress(%q, %t) returned error %v, want error: %t", test.localAddress, test.allowPublicBind, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAppName(t *testing.T) {
+	tests := []struct {
+		name    string
+		appName string
+		prefix  bool
+		wantErr bool
+	}{
+		{name: "label value", appName: "web-api"},
+		{name: "invalid label value", appName: "web api", wantErr: true},
+		{name: "label value ending in '-'", appName: "web-", wantErr: true},
+		{name: "prefix ending in '-'", appName: "web-", prefix: true},
+		{name: "prefix starting with '-'", appName: "-web", prefix: true, wantErr: true},
+		{name: "prefix with invalid character", appName: "web/", prefix: true, wantErr: true},
+		{name: "prefix of the maximum length", appName: strings.Repeat("a", 63), prefix: true},
+		{name: "prefix too long", appName: strings.Repeat("a", 64), prefix: true, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateAppName(test.appName, test.prefix)
+			if (err != nil) != test.wantErr {
+				t.Errorf("validateAppName(%q, %t) returned error %v, want error: %t", test.appName, test.prefix, err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package k8sforward
+
+import "runtime/debug"
+
+const modulePath = "github.com/merlincox/k8sforward"
+
+// Version returns the version of the k8sforward module compiled into the running binary, or "(devel)" if it cannot be determined.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+
+	version := ""
+	if info.Main.Path == modulePath {
+		version = info.Main.Version
+	} else {
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				version = dep.Version
+				break
+			}
+		}
+	}
+
+	if version == "" {
+		return "(devel)"
+	}
+	return version
+}
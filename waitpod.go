@@ -0,0 +1,152 @@
+package k8sforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// defaultPollInterval is the default interval between polls while waiting.
+const defaultPollInterval = time.Second
+
+var (
+	errWaitForReadyPod = errors.New("timed out waiting for a ready pod")
+	// errNoReadyPods is wrapped by the error returned when no pod becomes ready within s.WaitForReadyPod.
+	errNoReadyPods = errors.New("no ready pods found")
+)
+
+// waitForReadyPod waits, for at most s.WaitForReadyPod, until a pod matching the settings exists and is ready.
+// It watches pods rather than polling, so that it returns promptly once a pod becomes ready, but polls every
+// s.PollInterval if watching fails.
+func (s *Settings) waitForReadyPod(ctx context.Context, podClient corev1client.CoreV1Interface) error {
+	ctx, cancel := context.WithTimeoutCause(ctx, s.WaitForReadyPod, errWaitForReadyPod)
+	defer cancel()
+
+	namespace := s.namespace
+	if s.AllNamespaces {
+		namespace = metav1.NamespaceAll
+	}
+
+	listOptions := metav1.ListOptions{FieldSelector: s.podFieldSelector()}
+	var description string
+	if s.StatefulSetName != "" {
+		podName := fmt.Sprintf("%s-%d", s.StatefulSetName, s.Ordinal)
+		listOptions.FieldSelector = strings.Trim(fmt.Sprintf("%s,metadata.name=%s", listOptions.FieldSelector, podName), ",")
+		description = fmt.Sprintf("pod '%s' of StatefulSet '%s'", podName, s.StatefulSetName)
+	} else if s.podName != "" {
+		listOptions.FieldSelector = strings.Trim(fmt.Sprintf("%s,metadata.name=%s", listOptions.FieldSelector, s.podName), ",")
+		description = fmt.Sprintf("pod '%s'", s.podName)
+	} else {
+		listOptions.LabelSelector, description = s.podLabelSelector()
+	}
+
+	// List first, so that an already ready pod is found without waiting and the watch starts from the listed version.
+	pods, err := podClient.Pods(namespace).List(ctx, listOptions)
+	if err != nil {
+		return s.waitError(ctx, description, fmt.Errorf("error listing pods: %w", err))
+	}
+	for i := range pods.Items {
+		if s.isReadyMatch(&pods.Items[i]) {
+			return nil
+		}
+	}
+
+	s.verbosef("Waiting for a ready pod for %s", description)
+
+	listOptions.ResourceVersion = pods.ResourceVersion
+	err = s.watchForReadyPod(ctx, podClient.Pods(namespace), listOptions)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return s.waitError(ctx, description, err)
+	}
+
+	// Fall back to polling, such as when watching is not permitted or is broken by a proxy.
+	s.verbosef("Polling for a ready pod every %s, as watching pods failed: %v", s.PollInterval, err)
+	listOptions.ResourceVersion = ""
+	for {
+		select {
+		case <-time.After(s.PollInterval):
+		case <-ctx.Done():
+			return s.waitError(ctx, description, ctx.Err())
+		}
+
+		pods, err := podClient.Pods(namespace).List(ctx, listOptions)
+		if err != nil {
+			if ctx.Err() != nil {
+				return s.waitError(ctx, description, ctx.Err())
+			}
+			s.verbosef("Error listing pods: %v", err)
+			continue
+		}
+		for i := range pods.Items {
+			if s.isReadyMatch(&pods.Items[i]) {
+				return nil
+			}
+		}
+	}
+}
+
+// watchForReadyPod watches pods with listOptions until one is ready and matches the settings, or ctx is done.
+func (s *Settings) watchForReadyPod(ctx context.Context, podInterface corev1client.PodInterface, listOptions metav1.ListOptions) error {
+	watcher, err := podInterface.Watch(ctx, listOptions)
+	if err != nil {
+		return fmt.Errorf("error watching pods: %w", err)
+	}
+	defer watcher.Stop()
+
+	for {
+		select {
+		case event, ok := <-watcher.ResultChan():
+			if !ok {
+				return errors.New("watch of pods ended unexpectedly")
+			}
+			if event.Type == watch.Error {
+				return fmt.Errorf("error watching pods: %w", apierrors.FromObject(event.Object))
+			}
+			if pod, ok := event.Object.(*corev1.Pod); ok && event.Type != watch.Deleted && s.isReadyMatch(pod) {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// waitError returns err, or a timeout error if ctx has reached s.WaitForReadyPod.
+func (s *Settings) waitError(ctx context.Context, description string, err error) error {
+	if errors.Is(context.Cause(ctx), errWaitForReadyPod) {
+		return fmt.Errorf("%w for %s in '%s' context within %s", errNoReadyPods, description, s.ContextName, s.WaitForReadyPod)
+	}
+	return err
+}
+
+// isReadyMatch reports whether pod is running and ready, and matches the settings which cannot be selected for
+// when listing pods.
+func (s *Settings) isReadyMatch(pod *corev1.Pod) bool {
+	if pod.Status.Phase != corev1.PodRunning || !isPodReady(pod) {
+		return false
+	}
+	if s.AppNamePrefix && s.AppName != "" && !strings.HasPrefix(pod.Labels[s.AppLabelKey], s.AppName) {
+		return false
+	}
+	return matchesAnnotations(pod.Annotations, s.AnnotationSelector)
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
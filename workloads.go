@@ -0,0 +1,118 @@
+package k8sforward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// errNoPods is wrapped by the error returned when no running pods match the settings.
+var errNoPods = errors.New("no running pods found")
+
+// jobLabelSelector returns the label selector for the pods of s.JobName.
+func (s *Settings) jobLabelSelector(ctx context.Context) (string, error) {
+	job, err := s.clientset.BatchV1().Jobs(s.namespace).Get(ctx, s.JobName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", &Error{Code: CodeNoPods, Err: fmt.Errorf("no Job '%s' found in '%s' context", s.JobName, s.ContextName)}
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting Job '%s': %w", s.JobName, err)
+	}
+
+	if job.Spec.Selector == nil {
+		// Jobs created without a selector label their pods with the Job name.
+		return fmt.Sprintf("job-name=%s", s.JobName), nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return "", fmt.Errorf("error converting the selector of Job '%s': %w", s.JobName, err)
+	}
+	return selector.String(), nil
+}
+
+// deploymentLabelSelector returns the label selector for the pods of s.DeploymentName.
+func (s *Settings) deploymentLabelSelector(ctx context.Context) (string, error) {
+	deployment, err := s.clientset.AppsV1().Deployments(s.namespace).Get(ctx, s.DeploymentName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", &Error{Code: CodeNoPods, Err: fmt.Errorf("no Deployment '%s' found in '%s' context", s.DeploymentName, s.ContextName)}
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting Deployment '%s': %w", s.DeploymentName, err)
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return "", fmt.Errorf("error converting the selector of Deployment '%s': %w", s.DeploymentName, err)
+	}
+	return selector.String(), nil
+}
+
+// workloadLabelSelector returns the label selector for the pods of s.JobName, s.DeploymentName or the Service of s.Target.
+// The selectors of these workloads are immutable, and that of a Service rarely changes, so it is only resolved once.
+func (s *Settings) workloadLabelSelector(ctx context.Context) (string, error) {
+	if s.workloadSelector != "" {
+		return s.workloadSelector, nil
+	}
+
+	var err error
+	switch {
+	case s.JobName != "":
+		s.workloadSelector, err = s.jobLabelSelector(ctx)
+	case s.DeploymentName != "":
+		s.workloadSelector, err = s.deploymentLabelSelector(ctx)
+	case s.serviceName != "":
+		s.workloadSelector, err = s.serviceLabelSelector(ctx)
+	}
+	return s.workloadSelector, err
+}
+
+// explainNoPods adds to err, if it is due to no matching pods, whether the Deployment of the pods does not exist or is
+// scaled to zero replicas, as these need different action. The Deployment is s.DeploymentName, or otherwise any
+// Deployment labelled with AppName. Otherwise, or if the Deployment cannot be checked, err is returned unchanged.
+func (s *Settings) explainNoPods(ctx context.Context, err error) error {
+	if !errors.Is(err, errNoPods) && !errors.Is(err, errNoReadyPods) {
+		return err
+	}
+
+	var deployments []appsv1.Deployment
+	switch {
+	case s.DeploymentName != "":
+		deployment, getErr := s.clientset.AppsV1().Deployments(s.namespace).Get(ctx, s.DeploymentName, metav1.GetOptions{})
+		if getErr != nil {
+			return err
+		}
+		deployments = append(deployments, *deployment)
+	case s.AppName != "" && !s.AppNamePrefix && s.Selector == nil && s.StatefulSetName == "" && s.JobName == "" && !s.AllNamespaces:
+		list, listErr := s.clientset.AppsV1().Deployments(s.namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", s.AppLabelKey, s.AppName),
+		})
+		if listErr != nil {
+			return err
+		}
+		if len(list.Items) == 0 {
+			return fmt.Errorf("%w; no Deployment labelled %s=%s exists, so check the app name", err, s.AppLabelKey, s.AppName)
+		}
+		deployments = list.Items
+	default:
+		return err
+	}
+
+	for _, deployment := range deployments {
+		// A Deployment without replicas given has one.
+		if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas > 0 {
+			return err
+		}
+	}
+	names := make([]string, len(deployments))
+	for i, deployment := range deployments {
+		names[i] = deployment.Name
+	}
+	return fmt.Errorf("%w; Deployment '%s' is scaled to zero replicas, so scale it up, or wait with WaitForReadyPod "+
+		"for an autoscaler to scale it up", err, strings.Join(names, "', '"))
+}